@@ -4,19 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"go/build"
+	"go/token"
 	"go/types"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/callgraph/cha"
 	"golang.org/x/tools/go/callgraph/rta"
 	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/callgraph/vta"
 
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/ssa"
@@ -29,12 +37,27 @@ const (
 	CallGraphTypeStatic CallGraphType = "static"
 	CallGraphTypeCha    CallGraphType = "cha"
 	CallGraphTypeRta    CallGraphType = "rta"
+	CallGraphTypeVta    CallGraphType = "vta"
 )
 
 // ==[ type def/func: analysis   ]===============================================
 type renderOpts struct {
 	cacheDir string
 	focus    string
+	callers  int
+	callees  int
+	from     string
+	to       string
+	paths    int
+	pathLen  int
+	tree     string
+	dead     bool
+	deadURL  string
+	heat     string
+	cycles   bool
+	collapse bool
+	diff     *graphDiff // merged graph of compared revisions rendered instead
+	overlays renderOverlays
 	group    []string
 	ignore   []string
 	include  []string
@@ -43,6 +66,22 @@ type renderOpts struct {
 	refresh  bool
 	nostd    bool
 	algo     CallGraphType
+	format   string
+	renderer string // renderer of SVG images
+	main     string
+
+	// JSON output includes built-in layout with collapsed clusters, used by the viewer
+	layout    bool
+	collapsed []string
+}
+
+// renderOverlays holds data loaded from files given by flags,
+// which is drawn over the call graph.
+type renderOverlays struct {
+	cover   *coverProfile // -coverprofile
+	profile *pprofProfile // -pprof
+	rules   []*archRule   // -rules
+	changes *codeChanges  // -gitDiff or -changed
 }
 
 // mainPackages returns the main packages to analyze.
@@ -57,6 +96,9 @@ func mainPackages(pkgs []*ssa.Package) ([]*ssa.Package, error) {
 	if len(mains) == 0 {
 		return nil, fmt.Errorf("no main packages")
 	}
+	sort.Slice(mains, func(i, j int) bool {
+		return mains[i].Pkg.Path() < mains[j].Pkg.Path()
+	})
 	return mains, nil
 }
 
@@ -80,113 +122,451 @@ func initFuncs(pkgs []*ssa.Package) ([]*ssa.Function, error) {
 	return inits, nil
 }
 
+// libraryRoots returns all exported functions and methods of given packages,
+// these are used as roots for analysis in library mode.
+func libraryRoots(prog *ssa.Program, pkgs []*ssa.Package) []*ssa.Function {
+	var roots []*ssa.Function
+	seen := make(map[*ssa.Function]bool)
+	add := func(fn *ssa.Function) {
+		if fn == nil || fn.Synthetic != "" || seen[fn] {
+			return
+		}
+		seen[fn] = true
+		roots = append(roots, fn)
+	}
+	for _, p := range pkgs {
+		if p == nil {
+			continue
+		}
+		for _, member := range p.Members {
+			switch m := member.(type) {
+			case *ssa.Function:
+				if m.Object() != nil && m.Object().Exported() {
+					add(m)
+				}
+			case *ssa.Type:
+				// methods declared with value and pointer receivers
+				for _, T := range []types.Type{m.Type(), types.NewPointer(m.Type())} {
+					mset := prog.MethodSets.MethodSet(T)
+					for i := 0; i < mset.Len(); i++ {
+						sel := mset.At(i)
+						if !sel.Obj().Exported() {
+							continue
+						}
+						add(prog.MethodValue(sel))
+					}
+				}
+			}
+		}
+	}
+	return roots
+}
+
+// modulePackages returns import paths of packages declared in the analyzed
+// (main) modules, initial packages are used when modules are not available.
+func modulePackages(initial []*packages.Package) map[string]bool {
+	isInitial := make(map[*packages.Package]bool)
+	for _, p := range initial {
+		isInitial[p] = true
+	}
+	module := make(map[string]bool)
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if p.Module != nil && p.Module.Main || p.Module == nil && isInitial[p] {
+			module[p.PkgPath] = true
+		}
+	})
+	return module
+}
+
+// brokenPackages returns packages containing errors.
+func brokenPackages(initial []*packages.Package) []*graphPackage {
+	isInitial := make(map[*packages.Package]bool)
+	for _, p := range initial {
+		isInitial[p] = true
+	}
+	var broken []*graphPackage
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if len(p.Errors) == 0 {
+			return
+		}
+		b := &graphPackage{
+			Path:    p.PkgPath,
+			Name:    p.Name,
+			Initial: isInitial[p],
+		}
+		for _, err := range p.Errors {
+			b.Errors = append(b.Errors, err.Error())
+		}
+		broken = append(broken, b)
+	})
+	return broken
+}
+
+// partialPackages is like ssautil.AllPackages, but packages containing errors
+// are stubbed by creating them only from their (partial) types, so their
+// functions have no bodies, but calls into them are still present.
+// Packages only importing broken packages are built as usual.
+func partialPackages(initial []*packages.Package, mode ssa.BuilderMode) (*ssa.Program, []*ssa.Package) {
+	var fset *token.FileSet
+	if len(initial) > 0 {
+		fset = initial[0].Fset
+	}
+	prog := ssa.NewProgram(fset, mode)
+
+	created := make(map[*packages.Package]*ssa.Package)
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if p.Types == nil {
+			return
+		}
+		if len(p.Errors) > 0 {
+			logf("stubbing package with errors: %s", p.PkgPath)
+			created[p] = prog.CreatePackage(p.Types, nil, nil, true)
+			return
+		}
+		created[p] = prog.CreatePackage(p.Types, p.Syntax, p.TypesInfo, true)
+	})
+
+	var pkgs []*ssa.Package
+	for _, p := range initial {
+		pkgs = append(pkgs, created[p]) // may be nil
+	}
+	return prog, pkgs
+}
+
 // ==[ type def/func: analysis   ]===============================================
+
+// analysis is shared by all HTTP requests and must not be modified
+// after DoAnalysis, options for rendering are passed using renderOpts.
 type analysis struct {
-	opts      *renderOpts
-	prog      *ssa.Program
-	pkgs      []*ssa.Package
-	mainPkg   *ssa.Package
-	callgraph *callgraph.Graph
+	cfg      *packages.Config
+	args     []string
+	algo     CallGraphType
+	lib      bool
+	cacheDir string
+
+	// allowErrors builds program even if some packages contain errors,
+	// these are stubbed and reported in broken
+	allowErrors bool
+	broken      []*graphPackage
+
+	// packages of the program, used for resolving focus
+	packages []*graphPackage
+	mainPkgs []string
+
+	// fingerprint identifies analyzed sources in cache keys
+	fingerprint string
+
+	// progress shows phases of DoAnalysis on the server, call graphs
+	// computed later for requests are not reported
+	progress *progress
+
+	// generation is incremented by Reload, so renders of previous analysis
+	// finished after the reload are not cached under keys of the current one
+	generation int
+
+	// program is loaded lazily when call graph is not found in cache
+	mu     sync.Mutex
+	prog   *ssa.Program
+	pkgs   []*ssa.Package
+	mains  []*ssa.Package
+	module map[string]bool
+
+	// dead code is found only once it is requested
+	deadOnce sync.Once
+	dead     []*funcNode
+	deadErr  error
+
+	// callgraphs caches graphs computed by each algorithm and root,
+	// other than default can be requested by 'algo' and 'main' URL parameters
+	callgraphs map[graphKey]*callGraph
 }
 
-var Analysis *analysis
+// graphKey identifies call graph, root is the main package used
+// as the only entry point, it is empty when all are used.
+type graphKey struct {
+	algo CallGraphType
+	root string
+}
+
+// Analysis holds the current analysis,
+// it is replaced in watch mode when sources change.
+var Analysis atomic.Pointer[analysis]
 
 func (a *analysis) DoAnalysis(
 	algo CallGraphType,
 	dir string,
 	tests bool,
+	lib bool,
+	allowErrors bool,
+	cacheDir string,
 	args []string,
 ) error {
 	logf("begin analysis")
 	defer logf("analysis done")
+	defer func() { a.progress = nil }()
 
-	cfg := &packages.Config{
-		Mode:       packages.LoadAllSyntax,
+	a.cfg = &packages.Config{
+		Mode:       packages.LoadAllSyntax | packages.NeedModule,
 		Tests:      tests,
 		Dir:        dir,
 		BuildFlags: getBuildFlags(),
 	}
+	a.args = args
+	a.algo = algo
+	a.lib = lib
+	a.allowErrors = allowErrors
+	a.cacheDir = cacheDir
+	a.callgraphs = make(map[graphKey]*callGraph)
+
+	if cacheDir != "" {
+		logf("computing fingerprint of sources")
+		a.progress.Phase("listing packages", "computing fingerprint of sources")
+
+		fingerprint, err := sourcesFingerprint(a.cfg, args)
+		if err != nil {
+			return err
+		}
+		a.fingerprint = fingerprint
+	}
 
+	graph, err := a.CallGraph(algo, "")
+	if err != nil {
+		return err
+	}
+	a.packages = graph.Packages
+	a.mainPkgs = graph.Mains
+
+	return nil
+}
+
+// loadProgram loads packages and builds SSA-form program.
+func (a *analysis) loadProgram() error {
 	logf("loading packages")
+	a.progress.Phase("loading packages", "%s", strings.Join(a.args, " "))
 
-	initial, err := packages.Load(cfg, args...)
+	initial, err := packages.Load(a.cfg, a.args...)
 	if err != nil {
 		return err
 	}
 	if packages.PrintErrors(initial) > 0 {
-		return fmt.Errorf("packages contain errors")
+		a.progress.Errors(packageErrors(initial))
+		if !a.allowErrors {
+			return fmt.Errorf("packages contain errors")
+		}
+		a.broken = brokenPackages(initial)
+		log.Printf("%d packages contain errors, continuing with partial program", len(a.broken))
 	}
 
 	logf("loaded %d initial packages, building program", len(initial))
 
+	count := 0
+	packages.Visit(initial, nil, func(*packages.Package) { count++ })
+	a.progress.Phase("building SSA", "%d initial packages, %d packages in total", len(initial), count)
+
 	// Create and build SSA-form program representation.
 	mode := ssa.InstantiateGenerics
-	prog, pkgs := ssautil.AllPackages(initial, mode)
+	var (
+		prog *ssa.Program
+		pkgs []*ssa.Package
+	)
+	if a.broken != nil {
+		prog, pkgs = partialPackages(initial, mode)
+	} else {
+		prog, pkgs = ssautil.AllPackages(initial, mode)
+	}
 	prog.Build()
 
-	logf("build done, computing callgraph (algo: %v)", algo)
+	logf("build done")
+
+	a.prog = prog
+	a.pkgs = pkgs
+	a.module = modulePackages(initial)
+	if !a.lib {
+		// error is reported later by algorithms requiring main
+		a.mains, _ = mainPackages(prog.AllPackages())
+	}
+
+	return nil
+}
+
+// CallGraph returns call graph constructed using given algorithm,
+// the graph is computed only once and cached for subsequent calls.
+// Root selects single main package used as entry point by RTA,
+// when it is empty, all main packages are used.
+func (a *analysis) CallGraph(algo CallGraphType, root string) (*callGraph, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if algo != CallGraphTypeRta || a.lib {
+		root = ""
+	}
+	key := graphKey{algo, root}
+	if graph, ok := a.callgraphs[key]; ok {
+		return graph, nil
+	}
+
+	var snapshot string
+	if a.fingerprint != "" {
+		snapshot = a.snapshotPath(key)
+		graph, err := loadSnapshot(snapshot)
+		if err == nil {
+			logf("callgraph loaded from snapshot: %s", snapshot)
+			a.callgraphs[key] = graph
+			return graph, nil
+		}
+		if !os.IsNotExist(err) {
+			log.Printf("loading snapshot failed: %v", err)
+		}
+	}
+
+	if a.prog == nil {
+		if err := a.loadProgram(); err != nil {
+			return nil, err
+		}
+	}
+
+	logf("computing callgraph (algo: %v)", algo)
+	a.progress.Phase("computing callgraph", "algorithm: %s, %d packages", algo, len(a.prog.AllPackages()))
 
-	var graph *callgraph.Graph
-	var mainPkg *ssa.Package
+	var cg *callgraph.Graph
 
 	switch algo {
 	case CallGraphTypeStatic:
-		graph = static.CallGraph(prog)
+		cg = static.CallGraph(a.prog)
 	case CallGraphTypeCha:
-		graph = cha.CallGraph(prog)
+		cg = cha.CallGraph(a.prog)
 	case CallGraphTypeRta:
-		mains, err := mainPackages(prog.AllPackages())
-		if err != nil {
-			return err
-		}
 		var roots []*ssa.Function
-		mainPkg = mains[0]
-		for _, main := range mains {
-			roots = append(roots, main.Func("main"))
+		if a.lib {
+			roots = libraryRoots(a.prog, a.pkgs)
+			logf("library mode with %d exported roots", len(roots))
+		} else {
+			if len(a.mains) == 0 {
+				return nil, fmt.Errorf("no main packages")
+			}
+			for _, main := range a.mains {
+				if root == "" || main.Pkg.Path() == root {
+					roots = append(roots, main.Func("main"))
+				}
+			}
+			if len(roots) == 0 {
+				return nil, fmt.Errorf("no main package: %s", root)
+			}
 		}
 
-		inits, err := initFuncs(prog.AllPackages())
+		inits, err := initFuncs(a.prog.AllPackages())
 		if err != nil {
-			return err
+			return nil, err
 		}
 		for _, init := range inits {
 			roots = append(roots, init)
 		}
 
-		graph = rta.Analyze(roots, true).CallGraph
+		res := rta.Analyze(roots, true)
+		cg = res.CallGraph
+		// nodes are created only for calls, reachable functions
+		// making no calls, like leaf entry points, are added
+		for fn := range res.Reachable {
+			cg.CreateNode(fn)
+		}
+	case CallGraphTypeVta:
+		// VTA refines the initial CHA graph by tracking types
+		// flowing into interface and function values
+		cg = vta.CallGraph(ssautil.AllFunctions(a.prog), cha.CallGraph(a.prog))
 	default:
-		return fmt.Errorf("invalid call graph type: %s", a.opts.algo)
+		return nil, fmt.Errorf("invalid call graph type: %s", algo)
 	}
 
-	logf("callgraph resolved with %d nodes", len(graph.Nodes))
+	logf("callgraph resolved with %d nodes", len(cg.Nodes))
 
-	a.prog = prog
-	a.pkgs = pkgs
-	a.mainPkg = mainPkg
-	a.callgraph = graph
+	// stubs of broken packages have no syntax and would be deleted
+	// together with synthetic nodes, calls into them are added back
+	var stubCalls []*callgraph.Edge
+	for fn, n := range cg.Nodes {
+		if fn != nil && fn.Pkg != nil && isStub(fn) {
+			stubCalls = append(stubCalls, n.In...)
+		}
+	}
+	cg.DeleteSyntheticNodes()
+	for _, e := range stubCalls {
+		if cg.Nodes[e.Caller.Func] == e.Caller {
+			callgraph.AddEdge(e.Caller, e.Site, cg.CreateNode(e.Callee.Func))
+		}
+	}
+	graph := newCallGraph(a.prog, a.pkgs, a.mains, a.module, a.broken, cg)
+
+	if snapshot != "" {
+		if err := saveSnapshot(snapshot, graph); err != nil {
+			log.Printf("saving snapshot failed: %v", err)
+		}
+	}
+
+	a.callgraphs[key] = graph
+	return graph, nil
+}
+
+// Package returns package of the program with given import path or nil.
+func (a *analysis) Package(path string) *graphPackage {
+	i := sort.Search(len(a.packages), func(i int) bool {
+		return a.packages[i].Path >= path
+	})
+	if i < len(a.packages) && a.packages[i].Path == path {
+		return a.packages[i]
+	}
 	return nil
 }
 
-func (a *analysis) OptsSetup() {
-	a.opts = &renderOpts{
+// OptsSetup returns new render options with defaults from cmdline
+// and overlays loaded from files given by flags.
+func (a *analysis) OptsSetup(overlays renderOverlays) *renderOpts {
+	focus := *focusFlag
+	// libraries have no main package, focus the first target package instead
+	if a.lib && focus == "main" {
+		for _, p := range a.packages {
+			if p.Initial {
+				focus = p.Path
+				break
+			}
+		}
+	}
+	renderer := *rendererFlag
+	if renderer == "" {
+		renderer = defaultRenderer()
+	}
+	return &renderOpts{
 		cacheDir: *cacheDir,
-		focus:    *focusFlag,
+		focus:    focus,
+		callers:  *callerDepth,
+		callees:  *calleeDepth,
+		from:     *fromFlag,
+		to:       *toFlag,
+		paths:    *pathsFlag,
+		pathLen:  *pathLenFlag,
+		tree:     *treeFlag,
+		dead:     *deadFlag,
+		heat:     *heatFlag,
+		cycles:   *cyclesFlag,
+		collapse: *collapseFlag,
 		group:    []string{*groupFlag},
 		ignore:   []string{*ignoreFlag},
 		include:  []string{*includeFlag},
 		limit:    []string{*limitFlag},
 		nointer:  *nointerFlag,
 		nostd:    *nostdFlag,
+		algo:     a.algo,
+		format:   *outputFormat,
+		renderer: renderer,
+		overlays: overlays,
 	}
 }
 
-func (a *analysis) ProcessListArgs() (e error) {
+func (opts *renderOpts) ProcessListArgs() (e error) {
 	var groupBy []string
 	var ignorePaths []string
 	var includePaths []string
 	var limitPaths []string
 
-	for _, g := range strings.Split(a.opts.group[0], ",") {
+	for _, g := range strings.Split(opts.group[0], ",") {
 		g := strings.TrimSpace(g)
 		if g == "" {
 			continue
@@ -198,173 +578,380 @@ func (a *analysis) ProcessListArgs() (e error) {
 		groupBy = append(groupBy, g)
 	}
 
-	for _, p := range strings.Split(a.opts.ignore[0], ",") {
+	for _, p := range strings.Split(opts.ignore[0], ",") {
 		p = strings.TrimSpace(p)
 		if p != "" {
 			ignorePaths = append(ignorePaths, p)
 		}
 	}
 
-	for _, p := range strings.Split(a.opts.include[0], ",") {
+	for _, p := range strings.Split(opts.include[0], ",") {
 		p = strings.TrimSpace(p)
 		if p != "" {
 			includePaths = append(includePaths, p)
 		}
 	}
 
-	for _, p := range strings.Split(a.opts.limit[0], ",") {
+	for _, p := range strings.Split(opts.limit[0], ",") {
 		p = strings.TrimSpace(p)
 		if p != "" {
 			limitPaths = append(limitPaths, p)
 		}
 	}
 
-	a.opts.group = groupBy
-	a.opts.ignore = ignorePaths
-	a.opts.include = includePaths
-	a.opts.limit = limitPaths
+	if opts.tree != treeCallees && opts.tree != treeCallers {
+		e = errors.New("invalid tree option")
+		return
+	}
+	if _, ok := funcStatValue(&funcStats{}, opts.heat); opts.heat != "" && !ok {
+		e = errors.New("invalid heat option")
+		return
+	}
+	if opts.renderer != rendererGraphviz && opts.renderer != rendererBuiltin {
+		e = errors.New("invalid renderer option")
+		return
+	}
+
+	opts.group = groupBy
+	opts.ignore = ignorePaths
+	opts.include = includePaths
+	opts.limit = limitPaths
 
 	return
 }
 
-func (a *analysis) OverrideByHTTP(r *http.Request) {
+// selectGraph returns call graph of the main package selected by render
+// options, or merged graph of compared revisions, and filter of its calls.
+func (a *analysis) selectGraph(opts *renderOpts) (*callGraph, *edgeFilter, error) {
+	if opts.main != "" && !slices.Contains(a.mainPkgs, opts.main) {
+		return nil, nil, fmt.Errorf("not a main package: %v", opts.main)
+	}
+	cg, err := a.CallGraph(opts.algo, opts.main)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.diff != nil {
+		cg = opts.diff.Graph
+	}
+	return cg, opts.edgeFilter(), nil
+}
+
+// Stats computes metrics of the call graph filtered by render options.
+func (a *analysis) Stats(opts *renderOpts) (*graphStats, error) {
+	cg, filter, err := a.selectGraph(opts)
+	if err != nil {
+		return nil, err
+	}
+	return computeStats(cg, filter), nil
+}
+
+// Cycles finds recursion in the call graph filtered by render options.
+func (a *analysis) Cycles(opts *renderOpts) ([]*callCycle, error) {
+	cg, filter, err := a.selectGraph(opts)
+	if err != nil {
+		return nil, err
+	}
+	return findCycles(cg, filter), nil
+}
+
+// CheckRules returns calls of the call graph filtered
+// by render options, which are denied by the rules.
+func (a *analysis) CheckRules(opts *renderOpts) (map[*callEdge]*archRule, error) {
+	cg, filter, err := a.selectGraph(opts)
+	if err != nil {
+		return nil, err
+	}
+	return checkRules(cg, filter, opts.overlays.rules), nil
+}
+
+// Impact finds functions affected by the changes in the call graph
+// filtered by render options.
+func (a *analysis) Impact(opts *renderOpts) (*impactSet, error) {
+	cg, filter, err := a.selectGraph(opts)
+	if err != nil {
+		return nil, err
+	}
+	return opts.overlays.changes.impact(cg, filter), nil
+}
+
+// edgeFilter returns filter of calls, opts must be already
+// normalized by ProcessListArgs.
+func (opts *renderOpts) edgeFilter() *edgeFilter {
+	return &edgeFilter{
+		limitPaths:   opts.limit,
+		ignorePaths:  opts.ignore,
+		includePaths: opts.include,
+		nostd:        opts.nostd,
+		nointer:      opts.nointer,
+	}
+}
+
+func (opts *renderOpts) OverrideByHTTP(r *http.Request) {
 	if f := r.FormValue("f"); f == "all" {
-		a.opts.focus = ""
+		opts.focus = ""
 	} else if f != "" {
-		a.opts.focus = f
+		opts.focus = f
+	}
+	if c, err := strconv.Atoi(r.FormValue("callers")); err == nil && c >= 0 {
+		opts.callers = c
+	}
+	if c, err := strconv.Atoi(r.FormValue("callees")); err == nil && c >= 0 {
+		opts.callees = c
+	}
+	if from := r.FormValue("from"); from != "" {
+		opts.from = from
+	}
+	if to := r.FormValue("to"); to != "" {
+		opts.to = to
+	}
+	if n, err := strconv.Atoi(r.FormValue("paths")); err == nil && n > 0 {
+		opts.paths = n
+	}
+	if n, err := strconv.Atoi(r.FormValue("pathlen")); err == nil && n >= 0 {
+		opts.pathLen = n
+	}
+	if dead, err := strconv.ParseBool(r.FormValue("dead")); err == nil {
+		opts.dead = dead
+	}
+	if cycles, err := strconv.ParseBool(r.FormValue("cycles")); err == nil {
+		opts.cycles = cycles
+	}
+	if collapse, err := strconv.ParseBool(r.FormValue("collapse")); err == nil {
+		opts.collapse = collapse
+	}
+	if heat := r.FormValue("heat"); heat != "" {
+		opts.heat = heat
+	}
+	if tree := r.FormValue("tree"); tree != "" {
+		opts.tree = tree
 	}
 	if std := r.FormValue("std"); std != "" {
-		a.opts.nostd = false
+		opts.nostd = false
 	}
 	if inter := r.FormValue("nointer"); inter != "" {
-		a.opts.nointer = true
+		opts.nointer = true
 	}
 	if refresh := r.FormValue("refresh"); refresh != "" {
-		a.opts.refresh = true
+		opts.refresh = true
 	}
 	if g := r.FormValue("group"); g != "" {
-		a.opts.group[0] = g
+		opts.group[0] = g
 	}
 	if l := r.FormValue("limit"); l != "" {
-		a.opts.limit[0] = l
+		opts.limit[0] = l
 	}
 	if ign := r.FormValue("ignore"); ign != "" {
-		a.opts.ignore[0] = ign
+		opts.ignore[0] = ign
 	}
 	if inc := r.FormValue("include"); inc != "" {
-		a.opts.include[0] = inc
+		opts.include[0] = inc
+	}
+	if algo := r.FormValue("algo"); algo != "" {
+		opts.algo = CallGraphType(algo)
+	}
+	if f := r.FormValue("format"); f != "" {
+		opts.format = f
+	}
+	if renderer := r.FormValue("renderer"); renderer != "" {
+		opts.renderer = renderer
+	}
+	if m := r.FormValue("main"); m != "" {
+		opts.main = m
+	}
+	if layout, err := strconv.ParseBool(r.FormValue("layout")); err == nil {
+		opts.layout = layout
+	}
+	if c := r.FormValue("collapsed"); c != "" {
+		opts.collapsed = strings.Split(c, ",")
 	}
 	return
 }
 
 // basically do printOutput() with previously checking
-// focus option and respective package
-func (a *analysis) Render() ([]byte, error) {
+// focus option and respective package or function
+func (a *analysis) Render(opts *renderOpts) ([]byte, error) {
 	var (
-		err      error
-		ssaPkg   *ssa.Package
-		focusPkg *types.Package
+		err        error
+		focusPkg   *graphPackage
+		focusFuncs []*funcNode
+		focusEdges map[*callEdge]bool
 	)
 
 	start := time.Now()
 	logf("begin rendering")
 
-	if a.opts.focus != "" {
-		if ssaPkg = a.prog.ImportedPackage(a.opts.focus); ssaPkg == nil {
-			if strings.Contains(a.opts.focus, "/") {
+	pathMode := opts.from != "" || opts.to != ""
+	if pathMode && (opts.from == "" || opts.to == "") {
+		return nil, fmt.Errorf("path requires both from and to functions")
+	}
+	// with several main packages, focus the selected one
+	if opts.focus == "main" && len(a.mainPkgs) > 1 {
+		opts.focus = a.mainPkgs[0]
+		if opts.main != "" {
+			opts.focus = opts.main
+		}
+	}
+
+	key := a.cacheKey(opts)
+	if !opts.refresh {
+		if output, ok := renderCache.Get(key); ok {
+			logf("rendering done (cached output)")
+			return output, nil
+		}
+	}
+
+	cg, filter, err := a.selectGraph(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var costs *profileCosts
+	if opts.overlays.profile != nil {
+		costs = opts.overlays.profile.costs(cg)
+		if len(costs.observed) > 0 {
+			cg = cg.withCalls(costs.observed)
+		}
+	}
+
+	if pathMode {
+		from, err := findFunc(cg, opts.from)
+		if err == nil && from == nil {
+			err = fmt.Errorf("could not find function: %v", opts.from)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("path failed: %v", err)
+		}
+		to, err := findFunc(cg, opts.to)
+		if err == nil && to == nil {
+			err = fmt.Errorf("could not find function: %v", opts.to)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("path failed: %v", err)
+		}
+		logf("searching paths: %v -> %v (max: %d, max length: %d)", from, to, opts.paths, opts.pathLen)
+
+		paths := findPaths(from, to, opts.paths, opts.pathLen, filter)
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no call path from %v to %v", from, to)
+		}
+		logf("found %d paths", len(paths))
+
+		if opts.format == textFormat {
+			output := printPaths(paths, filter)
+			renderCache.Put(key, output)
+			return output, nil
+		}
+		focusFuncs = []*funcNode{from, to}
+		focusEdges = pathEdges(paths, filter)
+	} else if opts.focus != "" {
+		if focusPkg = a.Package(opts.focus); focusPkg == nil {
+			// try to find function by its name
+			focusFunc, err := findFunc(cg, opts.focus)
+			if err != nil {
+				return nil, fmt.Errorf("focus failed: %v", err)
+			}
+			if focusFunc != nil && opts.format == textFormat {
+				depth := opts.callees
+				if opts.tree == treeCallers {
+					depth = opts.callers
+				}
+				output := printTree(focusFunc, opts.tree, depth, filter)
+				renderCache.Put(key, output)
+				return output, nil
+			}
+			if focusFunc != nil {
+				logf("focusing function: %v (callers: %d, callees: %d)", focusFunc, opts.callers, opts.callees)
+				focusFuncs = []*funcNode{focusFunc}
+				focusEdges = neighborEdges(focusFunc, opts.callers, opts.callees, filter)
+			}
+		}
+		if focusPkg == nil && focusFuncs == nil {
+			if strings.Contains(opts.focus, "/") {
 				return nil, fmt.Errorf("focus failed: %v", err)
 			}
 			// try to find package by name
 			var foundPaths []string
-			for _, p := range a.pkgs {
-				if p.Pkg.Name() == a.opts.focus {
-					foundPaths = append(foundPaths, p.Pkg.Path())
+			for _, p := range a.packages {
+				if p.Initial && p.Name == opts.focus {
+					foundPaths = append(foundPaths, p.Path)
 				}
 			}
 			if len(foundPaths) == 0 {
-				return nil, fmt.Errorf("focus failed, could not find package: %v", a.opts.focus)
+				return nil, fmt.Errorf("focus failed, could not find package or function: %v", opts.focus)
 			} else if len(foundPaths) > 1 {
 				for _, p := range foundPaths {
 					fmt.Fprintf(os.Stderr, " - %s\n", p)
 				}
-				return nil, fmt.Errorf("focus failed, found multiple packages with name: %v", a.opts.focus)
+				return nil, fmt.Errorf("focus failed, found multiple packages with name: %v", opts.focus)
 			}
 			// found single package
-			if ssaPkg = a.prog.ImportedPackage(foundPaths[0]); ssaPkg == nil {
+			if focusPkg = a.Package(foundPaths[0]); focusPkg == nil {
 				return nil, fmt.Errorf("focus failed: %v", err)
 			}
 		}
-		focusPkg = ssaPkg.Pkg
-		logf("focusing package: %v (path: %v)", focusPkg.Name(), focusPkg.Path())
+		if focusPkg != nil {
+			logf("focusing package: %v (path: %v)", focusPkg.Name, focusPkg.Path)
+		}
 	}
 
-	dot, err := printOutput(
-		a.prog,
-		a.mainPkg,
-		a.callgraph,
-		focusPkg,
-		a.opts.limit,
-		a.opts.ignore,
-		a.opts.include,
-		a.opts.group,
-		a.opts.nostd,
-		a.opts.nointer,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("processing failed: %v", err)
+	if opts.format == textFormat {
+		return nil, fmt.Errorf("%s format is supported only for paths and focused functions", textFormat)
 	}
 
-	logf("rendering done (took %v sec)", time.Since(start).Round(time.Millisecond).Seconds())
-
-	return dot, nil
-}
-
-func (a *analysis) FindCachedImg() string {
-	if a.opts.cacheDir == "" || a.opts.refresh {
-		return ""
+	overlays := &graphOverlays{
+		deadURL:  opts.deadURL,
+		heat:     opts.heat,
+		collapse: opts.collapse,
+		diff:     opts.diff,
+		cover:    opts.overlays.cover,
+		costs:    costs,
 	}
-
-	focus := a.opts.focus
-	if focus == "" {
-		focus = "all"
+	if opts.heat != "" {
+		overlays.stats = computeStats(cg, filter)
 	}
-	focusFilePath := focus + "." + *outputFormat
-	absFilePath := filepath.Join(a.opts.cacheDir, focusFilePath)
-
-	if exists, err := pathExists(absFilePath); err != nil || !exists {
-		log.Println("not cached img:", absFilePath)
-		return ""
+	if opts.cycles || opts.collapse {
+		overlays.cycles = findCycles(cg, filter)
 	}
-
-	log.Println("hit cached img")
-	return absFilePath
-}
-
-func (a *analysis) CacheImg(img string) error {
-	if a.opts.cacheDir == "" || img == "" {
-		return nil
+	if opts.overlays.rules != nil {
+		overlays.violations = checkRules(cg, filter, opts.overlays.rules)
 	}
-
-	focus := a.opts.focus
-	if focus == "" {
-		focus = "all"
+	if opts.overlays.changes != nil {
+		overlays.impact = opts.overlays.changes.impact(cg, filter)
 	}
-	absCacheDirPrefix := filepath.Join(a.opts.cacheDir, focus)
-	absCacheDirPath := strings.TrimRightFunc(absCacheDirPrefix, func(r rune) bool {
-		return r != '\\' && r != '/'
-	})
-	err := os.MkdirAll(absCacheDirPath, os.ModePerm)
-	if err != nil {
-		return err
+	if opts.dead {
+		if overlays.dead, err = a.DeadCode(); err != nil {
+			return nil, fmt.Errorf("finding dead code failed: %v", err)
+		}
+		if overlays.dead == nil {
+			overlays.dead = []*funcNode{}
+		}
+	}
+
+	write := (*dotGraph).WriteDot
+	if f, ok := opts.graphFormat(); ok {
+		write = f.write
 	}
 
-	absFilePath := absCacheDirPrefix + "." + *outputFormat
-	_, err = copyFile(img, absFilePath)
+	dot, err := printOutput(
+		cg,
+		opts.main,
+		focusPkg,
+		focusFuncs,
+		focusEdges,
+		filter,
+		opts.group,
+		overlays,
+		write,
+	)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("processing failed: %v", err)
 	}
 
-	return nil
+	renderCache.Put(key, dot)
+
+	logf("rendering done (took %v sec)", time.Since(start).Round(time.Millisecond).Seconds())
+
+	return dot, nil
 }
 
 func pathExists(path string) (bool, error) {
@@ -404,6 +991,38 @@ func copyFile(src, dst string) (int64, error) {
 	return nBytes, err
 }
 
+// workspacePatterns returns package patterns matching all packages
+// of modules used by the go.work workspace.
+func workspacePatterns(dir string) ([]string, error) {
+	cmd := exec.Command("go", "env", "GOWORK")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go env GOWORK: %v", err)
+	}
+	if gowork := strings.TrimSpace(string(out)); gowork == "" || gowork == "off" {
+		return nil, errors.New("no go.work workspace found")
+	}
+
+	// in workspace mode all used modules are main modules
+	cmd = exec.Command("go", "list", "-m", "-f", "{{.Dir}}")
+	cmd.Dir = dir
+	out, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -m: %v", err)
+	}
+
+	var patterns []string
+	for _, d := range strings.Split(string(out), "\n") {
+		if d = strings.TrimSpace(d); d != "" {
+			patterns = append(patterns, filepath.Join(d, "..."))
+		}
+	}
+	logf("workspace patterns: %v", patterns)
+
+	return patterns, nil
+}
+
 func getBuildFlags() []string {
 	buildFlagTags := getBuildFlagTags(build.Default.BuildTags)
 	if len(buildFlagTags) == 0 {
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testModule writes files of module example.com/m into temporary directory.
+func testModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/m\n\ngo 1.22\n"
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// testCallees returns names of functions called by the function.
+func testCallees(g *callGraph, name string) map[string]bool {
+	callees := make(map[string]bool)
+	for _, f := range g.Funcs {
+		if f.Name != name {
+			continue
+		}
+		for _, e := range f.out {
+			callees[e.callee.Name] = true
+		}
+	}
+	return callees
+}
+
+func TestCallGraphAlgorithms(t *testing.T) {
+	dir := testModule(t, map[string]string{
+		"main.go": `package main
+
+type I interface{ Do() }
+
+type A struct{}
+
+func (A) Do() {}
+
+type B struct{}
+
+func (B) Do() {}
+
+func main() {
+	var i I = A{}
+	i.Do()
+}
+
+func unused() I { return B{} }
+`,
+	})
+	a := new(analysis)
+	if err := a.DoAnalysis(CallGraphTypeStatic, dir, false, false, false, "", []string{"."}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		algo    CallGraphType
+		want    []string
+		wantNot []string
+	}{
+		// interface calls are not resolved
+		{CallGraphTypeStatic, nil, []string{"(A).Do", "(B).Do"}},
+		// all implementations are callees
+		{CallGraphTypeCha, []string{"(A).Do", "(B).Do"}, nil},
+		// B is never converted to interface in reachable code
+		{CallGraphTypeRta, []string{"(A).Do"}, []string{"(B).Do"}},
+		// only A flows into the interface value
+		{CallGraphTypeVta, []string{"(A).Do"}, []string{"(B).Do"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.algo), func(t *testing.T) {
+			g, err := a.CallGraph(tt.algo, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			callees := testCallees(g, "main")
+			for _, name := range tt.want {
+				if !callees[name] {
+					t.Errorf("main does not call %s, callees: %v", name, callees)
+				}
+			}
+			for _, name := range tt.wantNot {
+				if callees[name] {
+					t.Errorf("main calls %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestLibraryRoots(t *testing.T) {
+	dir := testModule(t, map[string]string{
+		"lib/lib.go": `package lib
+
+func API() { helper() }
+
+func helper() {}
+
+type T struct{}
+
+func (T) Method() { method() }
+
+func (*T) PtrMethod() { method() }
+
+func method() {}
+
+func internal() { other() }
+
+func other() {}
+`,
+	})
+	a := new(analysis)
+	if err := a.DoAnalysis(CallGraphTypeRta, dir, false, true, false, "", []string{"./lib"}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := a.CallGraph(CallGraphTypeRta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exported functions and methods are reachable roots
+	for caller, callee := range map[string]string{
+		"API":            "helper",
+		"(T).Method":     "method",
+		"(*T).PtrMethod": "method",
+	} {
+		if !testCallees(g, caller)[callee] {
+			t.Errorf("call %s -> %s is missing", caller, callee)
+		}
+	}
+	if testCallees(g, "internal")["other"] {
+		t.Error("unexported function is a root")
+	}
+}
+
+func TestAllowErrors(t *testing.T) {
+	files := func() map[string]string {
+		return map[string]string{
+			"main.go": `package main
+
+import (
+	"example.com/m/broken"
+	"example.com/m/ok"
+)
+
+func main() {
+	ok.Run()
+	broken.Run()
+}
+`,
+			"ok/ok.go": `package ok
+
+func Run() {}
+`,
+			"broken/broken.go": `package broken
+
+func Run() { undefined() }
+`,
+		}
+	}
+
+	if err := new(analysis).DoAnalysis(CallGraphTypeStatic, testModule(t, files()), false, false, false, "", []string{"."}); err == nil {
+		t.Error("analysis of broken package succeeded without -allow-errors")
+	}
+
+	a := new(analysis)
+	if err := a.DoAnalysis(CallGraphTypeStatic, testModule(t, files()), false, false, true, "", []string{"."}); err != nil {
+		t.Fatal(err)
+	}
+	opts := a.OptsSetup(renderOverlays{})
+	opts.format = "dot"
+	if err := opts.ProcessListArgs(); err != nil {
+		t.Fatal(err)
+	}
+	output, err := a.Render(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := string(output)
+
+	// calls into both packages are rendered, only the broken one is marked
+	for _, call := range []string{
+		`"example.com/m.main" -> "example.com/m/ok.Run"`,
+		`"example.com/m.main" -> "example.com/m/broken.Run"`,
+	} {
+		if !strings.Contains(dot, call) {
+			t.Errorf("call %s is missing", call)
+		}
+	}
+	if n := strings.Count(dot, `\nerrors:`); n != 1 {
+		t.Errorf("%d packages marked broken, want 1", n)
+	}
+	if !strings.Contains(dot, `package: example.com/m/broken\nerrors:`) {
+		t.Error("broken package is not marked")
+	}
+	if !strings.Contains(dot, "1 packages contain errors") {
+		t.Error("errors of broken package are not listed in the label")
+	}
+}
+
+func TestMultipleMains(t *testing.T) {
+	dir := testModule(t, map[string]string{
+		"cmd/a/main.go": "package main\n\nfunc main() {}\n",
+		"cmd/b/main.go": "package main\n\nfunc main() {}\n",
+	})
+	a := new(analysis)
+	if err := a.DoAnalysis(CallGraphTypeStatic, dir, false, false, false, "", []string{"./..."}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		main string
+		want string
+	}{
+		{"", "example.com/m/cmd/a, example.com/m/cmd/b"},
+		{"example.com/m/cmd/b", "example.com/m/cmd/b"},
+	}
+	for _, tt := range tests {
+		opts := a.OptsSetup(renderOverlays{})
+		opts.format = "json"
+		opts.main = tt.main
+		if err := opts.ProcessListArgs(); err != nil {
+			t.Fatal(err)
+		}
+		output, err := a.Render(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var g jsonGraph
+		if err := json.Unmarshal(output, &g); err != nil {
+			t.Fatal(err)
+		}
+		if g.Title != tt.want {
+			t.Errorf("title with main %q: %q, want %q", tt.main, g.Title, tt.want)
+		}
+	}
+}
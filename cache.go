@@ -0,0 +1,251 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/build"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheKey returns hash of everything affecting the rendered output,
+// opts must be already normalized by ProcessListArgs.
+func (a *analysis) cacheKey(opts *renderOpts) string {
+	sorted := func(s []string) []string {
+		s = append([]string(nil), s...)
+		sort.Strings(s)
+		return s
+	}
+	// images are all converted from the same DOT output
+	format := opts.format
+	if _, ok := opts.graphFormat(); !ok && format != textFormat {
+		format = "dot"
+	}
+
+	var diff, cover, prof, changed string
+	if opts.diff != nil {
+		diff = opts.diff.Base + ".." + opts.diff.Head
+	}
+	if opts.overlays.cover != nil {
+		cover = opts.overlays.cover.Sum
+	}
+	if opts.overlays.profile != nil {
+		prof = opts.overlays.profile.Sum
+	}
+	if opts.overlays.changes != nil {
+		changed = fmt.Sprint(opts.overlays.changes.files)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "diff=%q cover=%s pprof=%s changes=%q\n", diff, cover, prof, changed)
+	fmt.Fprintf(h, "focus=%q callers=%d callees=%d\n", opts.focus, opts.callers, opts.callees)
+	fmt.Fprintf(h, "tree=%s dead=%v deadURL=%q heat=%s\n", opts.tree, opts.dead, opts.deadURL, opts.heat)
+	fmt.Fprintf(h, "cycles=%v collapse=%v rules=%v\n", opts.cycles, opts.collapse, opts.overlays.rules)
+	fmt.Fprintf(h, "from=%q to=%q paths=%d pathLen=%d\n", opts.from, opts.to, opts.paths, opts.pathLen)
+	fmt.Fprintf(h, "group=%q\n", sorted(opts.group))
+	fmt.Fprintf(h, "ignore=%q\n", sorted(opts.ignore))
+	fmt.Fprintf(h, "include=%q\n", sorted(opts.include))
+	fmt.Fprintf(h, "limit=%q\n", sorted(opts.limit))
+	fmt.Fprintf(h, "nointer=%v nostd=%v\n", opts.nointer, opts.nostd)
+	fmt.Fprintf(h, "algo=%s main=%s format=%s renderer=%s\n", opts.algo, opts.main, format, opts.renderer)
+	fmt.Fprintf(h, "layout=%v collapsed=%q\n", opts.layout, sorted(opts.collapsed))
+	fmt.Fprintf(h, "tags=%q\n", build.Default.BuildTags)
+	fmt.Fprintf(h, "minlen=%v nodesep=%v nodeshape=%q nodestyle=%q rankdir=%q\n",
+		minlen, nodesep, nodeshape, nodestyle, rankdir)
+	fmt.Fprintf(h, "lib=%v allowErrors=%v sources=%s generation=%d\n", a.lib, a.allowErrors, a.fingerprint, a.generation)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedImgPath returns path of cached image for given key and format.
+func cachedImgPath(cacheDir, key, format string) string {
+	return filepath.Join(cacheDir, key+"."+format)
+}
+
+func (a *analysis) FindCachedImg(opts *renderOpts, key string) string {
+	if opts.cacheDir == "" || opts.refresh {
+		return ""
+	}
+	if _, ok := opts.graphFormat(); ok || opts.format == "dot" || opts.format == textFormat {
+		return ""
+	}
+
+	absFilePath := cachedImgPath(opts.cacheDir, key, opts.format)
+
+	if exists, err := pathExists(absFilePath); err != nil || !exists {
+		log.Println("not cached img:", absFilePath)
+		return ""
+	}
+
+	// modification time is used for tracking recently used images
+	now := time.Now()
+	if err := os.Chtimes(absFilePath, now, now); err != nil {
+		log.Printf("touching cached img failed: %v", err)
+	}
+
+	log.Println("hit cached img")
+	return absFilePath
+}
+
+func (a *analysis) CacheImg(opts *renderOpts, key string, img string) error {
+	if opts.cacheDir == "" || img == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(opts.cacheDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyFile(img, cachedImgPath(opts.cacheDir, key, opts.format))
+	if err != nil {
+		return err
+	}
+
+	return evictCachedImgs(opts.cacheDir, *cacheSizeFlag<<20)
+}
+
+// isCachedImg reports whether name is a file name created by CacheImg.
+func isCachedImg(name string) bool {
+	key, _, ok := strings.Cut(name, ".")
+	if !ok || len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// evictCachedImgs removes least recently used images from cache directory
+// until their total size fits the limit, zero limit means unlimited.
+func evictCachedImgs(cacheDir string, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		files []os.FileInfo
+		total int64
+	)
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !isCachedImg(e.Name()) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fi)
+		total += fi.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for _, fi := range files {
+		if total <= limit {
+			break
+		}
+		logf("evicting cached img: %s", fi.Name())
+		if err := os.Remove(filepath.Join(cacheDir, fi.Name())); err != nil {
+			return err
+		}
+		total -= fi.Size()
+	}
+
+	return nil
+}
+
+// ==[ type def/func: outputCache   ]============================================
+
+// outputCache keeps recently rendered outputs in memory,
+// so repeated views do not need to run printOutput again.
+type outputCache struct {
+	mu    sync.Mutex
+	limit int64
+	size  int64
+	order *list.List
+	items map[string]*list.Element
+}
+
+type outputCacheItem struct {
+	key    string
+	output []byte
+}
+
+var renderCache *outputCache
+
+// newOutputCache returns cache holding at most limit bytes,
+// nil is returned for zero limit which disables caching.
+func newOutputCache(limit int64) *outputCache {
+	if limit <= 0 {
+		return nil
+	}
+	return &outputCache{
+		limit: limit,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *outputCache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*outputCacheItem).output, true
+}
+
+// Reset removes all outputs from the cache.
+func (c *outputCache) Reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+}
+
+func (c *outputCache) Put(key string, output []byte) {
+	if c == nil || int64(len(output)) > c.limit {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.size -= int64(len(e.Value.(*outputCacheItem).output))
+		c.order.Remove(e)
+	}
+	c.items[key] = c.order.PushFront(&outputCacheItem{key: key, output: output})
+	c.size += int64(len(output))
+
+	for c.size > c.limit {
+		e := c.order.Back()
+		item := e.Value.(*outputCacheItem)
+		c.order.Remove(e)
+		delete(c.items, item.key)
+		c.size -= int64(len(item.output))
+	}
+}
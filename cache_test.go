@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestCacheKeyGeneration(t *testing.T) {
+	opts := &renderOpts{format: "svg"}
+	a := &analysis{}
+	reloaded := &analysis{generation: 1}
+	if a.cacheKey(opts) == reloaded.cacheKey(opts) {
+		t.Error("analyses of different generations have the same cache key")
+	}
+	if a.cacheKey(opts) != (&analysis{}).cacheKey(opts) {
+		t.Error("cache key of the same analysis differs")
+	}
+}
+
+func TestCacheKeyRenderer(t *testing.T) {
+	a := &analysis{}
+	graphviz := &renderOpts{format: "svg", renderer: rendererGraphviz}
+	builtin := &renderOpts{format: "svg", renderer: rendererBuiltin}
+	if a.cacheKey(graphviz) == a.cacheKey(builtin) {
+		t.Error("images of different renderers have the same cache key")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/tools/cover"
+)
+
+// ==[ type def/func: coverProfile ]=============================================
+
+// coverProfile holds blocks of profile written by go test -coverprofile,
+// files are named by import path of their package, e.g. example.com/pkg/file.go.
+type coverProfile struct {
+	Path string
+	Sum  string // hash of the profile, used in cache keys
+
+	files map[string][]cover.ProfileBlock
+	pkgs  map[string]*coverStmts
+}
+
+type coverStmts struct {
+	Covered, Total int
+}
+
+// Percent returns percentage of covered statements.
+func (s coverStmts) Percent() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return 100 * float64(s.Covered) / float64(s.Total)
+}
+
+func (s *coverStmts) add(b cover.ProfileBlock) {
+	s.Total += b.NumStmt
+	if b.Count > 0 {
+		s.Covered += b.NumStmt
+	}
+}
+
+// loadCoverProfile reads coverage profile, blocks of the same file
+// from several profiles (e.g. using -coverpkg) are already merged.
+func loadCoverProfile(file string) (*coverProfile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := cover.ParseProfiles(file)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	p := &coverProfile{
+		Path:  file,
+		Sum:   hex.EncodeToString(sum[:]),
+		files: make(map[string][]cover.ProfileBlock),
+		pkgs:  make(map[string]*coverStmts),
+	}
+	for _, prof := range profiles {
+		p.files[prof.FileName] = prof.Blocks
+		pkg := path.Dir(prof.FileName)
+		if p.pkgs[pkg] == nil {
+			p.pkgs[pkg] = &coverStmts{}
+		}
+		for _, b := range prof.Blocks {
+			p.pkgs[pkg].add(b)
+		}
+	}
+
+	logf("loaded coverage of %d files from %s", len(p.files), file)
+
+	return p, nil
+}
+
+// blocks returns blocks of the file of given package.
+func (p *coverProfile) blocks(pkg string, pos token.Position) []cover.ProfileBlock {
+	if pos.Filename == "" {
+		return nil
+	}
+	return p.files[pkg+"/"+filepath.Base(pos.Filename)]
+}
+
+func before(line, col int, pos token.Position) bool {
+	return line < pos.Line || line == pos.Line && col <= pos.Column
+}
+
+// Func returns statements of the function covered by the profile,
+// closures are counted as part of their enclosing function.
+// Functions not present in the profile have no statements.
+func (p *coverProfile) Func(f *funcNode) coverStmts {
+	var s coverStmts
+	if f.End.Line == 0 {
+		return s
+	}
+	for _, b := range p.blocks(f.Pkg, f.Pos) {
+		if before(f.Pos.Line, f.Pos.Column, token.Position{Line: b.StartLine, Column: b.StartCol}) &&
+			before(b.EndLine, b.EndCol, f.End) {
+			s.add(b)
+		}
+	}
+	return s
+}
+
+// Package returns statements of the package covered by the profile.
+func (p *coverProfile) Package(path string) (coverStmts, bool) {
+	s, ok := p.pkgs[path]
+	if !ok {
+		return coverStmts{}, false
+	}
+	return *s, true
+}
+
+// notExecuted reports whether all calls between functions of the edge are
+// in blocks never executed, calls outside the profile are not reported.
+func (p *coverProfile) notExecuted(edge *callEdge) bool {
+	for _, e := range edge.caller.out {
+		if e.callee != edge.callee {
+			continue
+		}
+		executed := true
+		for _, b := range p.blocks(e.caller.Pkg, e.Pos) {
+			if before(b.StartLine, b.StartCol, e.Pos) && before(e.Pos.Line, e.Pos.Column, token.Position{Line: b.EndLine, Column: b.EndCol}) {
+				executed = b.Count > 0
+				break
+			}
+		}
+		if executed {
+			return false
+		}
+	}
+	return true
+}
+
+// coverColor returns color for percentage of covered statements,
+// going from red over yellow to green.
+func coverColor(percent float64) string {
+	return gradientColor(percent/100, [][3]float64{
+		{0xf4, 0xa6, 0xa6},
+		{0xff, 0xf3, 0xb0},
+		{0xb7, 0xe4, 0xb7},
+	})
+}
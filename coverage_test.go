@@ -0,0 +1,98 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// coverageFixture is profile of example.com/a/a.go, where Run spans lines
+// 3-12 and Other lines 20-22. Blocks on lines 5-9 were never executed.
+const coverageFixture = `mode: set
+example.com/a/a.go:3.14,5.10 2 1
+example.com/a/a.go:5.10,7.3 1 0
+example.com/a/a.go:7.3,9.3 1 0
+example.com/a/a.go:9.3,11.3 1 1
+example.com/a/a.go:20.15,22.2 3 0
+`
+
+func TestCoverProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cover.out")
+	if err := os.WriteFile(file, []byte(coverageFixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadCoverProfile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := testCallGraph(
+		"a.Run -> a.Used", "a.Run -> a.Unused",
+		"a.Run -> a.Mixed", "a.Run -> a.Mixed",
+		"b.Outside -> a.Unused",
+	)
+	sites := []token.Position{
+		{Filename: "/src/a/a.go", Line: 4, Column: 3},
+		{Filename: "/src/a/a.go", Line: 6, Column: 3},
+		{Filename: "/src/a/a.go", Line: 8, Column: 3},
+		{Filename: "/src/a/a.go", Line: 10, Column: 3},
+		{Filename: "/src/b/b.go", Line: 4, Column: 3},
+	}
+	for i, e := range g.Edges {
+		e.Pos = sites[i]
+	}
+	run := testFunc(t, g, "a.Run")
+	run.Pos = token.Position{Filename: "/src/a/a.go", Line: 3, Column: 1}
+	run.End = token.Position{Filename: "/src/a/a.go", Line: 12, Column: 2}
+	other := &funcNode{
+		Pkg: "example.com/a",
+		Pos: token.Position{Filename: "/src/a/a.go", Line: 20, Column: 1},
+		End: token.Position{Filename: "/src/a/a.go", Line: 22, Column: 2},
+	}
+
+	funcs := []struct {
+		name string
+		f    *funcNode
+		want coverStmts
+	}{
+		{"Run", run, coverStmts{Covered: 3, Total: 5}},
+		{"Other", other, coverStmts{Covered: 0, Total: 3}},
+		// synthetic functions have no end
+		{"Used", testFunc(t, g, "a.Used"), coverStmts{}},
+	}
+	for _, tt := range funcs {
+		if got := p.Func(tt.f); got != tt.want {
+			t.Errorf("Func(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if got, ok := p.Package("example.com/a"); !ok || got != (coverStmts{Covered: 3, Total: 8}) {
+		t.Errorf("Package(example.com/a) = %+v, %v", got, ok)
+	}
+	if _, ok := p.Package("example.com/b"); ok {
+		t.Error("package without profile is covered")
+	}
+
+	calls := []struct {
+		caller, callee string
+		want           bool
+	}{
+		{"a.Run", "a.Used", false},
+		{"a.Run", "a.Unused", true},
+		// one of the call sites was executed
+		{"a.Run", "a.Mixed", false},
+		// calls outside the profile are not reported
+		{"b.Outside", "a.Unused", false},
+	}
+	for _, tt := range calls {
+		caller, callee := testFunc(t, g, tt.caller), testFunc(t, g, tt.callee)
+		for _, e := range caller.out {
+			if e.callee != callee {
+				continue
+			}
+			if got := p.notExecuted(e); got != tt.want {
+				t.Errorf("notExecuted(%s -> %s) = %v, want %v", tt.caller, tt.callee, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DeadCode returns functions declared in the analyzed module, which are
+// not reachable from the entry points (main, init or exported functions
+// in library mode) according to RTA, sorted by package and position.
+// Closures are omitted, they are unreachable together with their parent.
+func (a *analysis) DeadCode() ([]*funcNode, error) {
+	a.deadOnce.Do(func() {
+		a.dead, a.deadErr = a.findDeadCode()
+	})
+	return a.dead, a.deadErr
+}
+
+func (a *analysis) findDeadCode() ([]*funcNode, error) {
+	logf("finding dead code")
+
+	// static call graph contains all functions of the program
+	all, err := a.CallGraph(CallGraphTypeStatic, "")
+	if err != nil {
+		return nil, err
+	}
+	reach, err := a.CallGraph(CallGraphTypeRta, "")
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make(map[string]bool)
+	for _, f := range reach.Funcs {
+		reachable[f.ID] = true
+		// generic function is reachable through its instances
+		if f.Origin != "" {
+			reachable[f.Origin] = true
+		}
+	}
+	module := make(map[string]bool)
+	for _, p := range all.Packages {
+		if p.Module {
+			module[p.Path] = true
+		}
+	}
+
+	var dead []*funcNode
+	for _, f := range all.Funcs {
+		if !module[f.Pkg] || f.Closure || f.Origin != "" || reachable[f.ID] {
+			continue
+		}
+		// synthetic package initializer, declared init functions
+		// are named init#1, init#2...
+		if f.Name == "init" {
+			continue
+		}
+		dead = append(dead, f)
+	}
+	sort.SliceStable(dead, func(i, j int) bool {
+		pi, pj := dead[i].Pos, dead[j].Pos
+		if dead[i].Pkg != dead[j].Pkg {
+			return dead[i].Pkg < dead[j].Pkg
+		}
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+
+	logf("found %d unreachable functions", len(dead))
+
+	return dead, nil
+}
+
+// printDeadCode writes unreachable functions grouped by package as text.
+func printDeadCode(w io.Writer, dead []*funcNode) error {
+	var buf bytes.Buffer
+	for i, f := range dead {
+		if i == 0 || dead[i-1].Pkg != f.Pkg {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			fmt.Fprintf(&buf, "%s\n", f.Pkg)
+		}
+		fmt.Fprintf(&buf, "  %s: %s\n", f.Pos, f.Name)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+type jsonDeadPackage struct {
+	Package string         `json:"package"`
+	Funcs   []jsonDeadFunc `json:"funcs"`
+}
+
+type jsonDeadFunc struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Exported bool         `json:"exported"`
+	Position jsonPosition `json:"position"`
+}
+
+// writeDeadCodeJSON writes unreachable functions grouped by package as JSON.
+func writeDeadCodeJSON(w io.Writer, dead []*funcNode) error {
+	out := []*jsonDeadPackage{}
+	for i, f := range dead {
+		if i == 0 || dead[i-1].Pkg != f.Pkg {
+			out = append(out, &jsonDeadPackage{Package: f.Pkg})
+		}
+		p := out[len(out)-1]
+		p.Funcs = append(p.Funcs, jsonDeadFunc{
+			ID:       f.ID,
+			Name:     f.Name,
+			Exported: f.Exported,
+			Position: newJSONPosition(f.Pos),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDeadCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		lib   bool
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "library",
+			lib:  true,
+			files: map[string]string{
+				"lib/lib.go": `package lib
+
+var v = value()
+
+func value() int { return 1 }
+
+func init() { setup() }
+
+func setup() {}
+
+func Leaf() {}
+
+func Used() { helper() }
+
+func helper() {}
+
+func unused() { helper() }
+
+type T struct{}
+
+func (T) Method() {}
+
+func (T) unused() {}
+`,
+			},
+			want: []string{"unused", "(T).unused"},
+		},
+		{
+			name: "main",
+			files: map[string]string{
+				"main.go": `package main
+
+func main() {}
+
+func Exported() {}
+
+func unused() { Exported() }
+`,
+			},
+			want: []string{"Exported", "unused"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testModule(t, tt.files)
+			a := new(analysis)
+			if err := a.DoAnalysis(CallGraphTypeStatic, dir, false, tt.lib, false, "", []string{"./..."}); err != nil {
+				t.Fatal(err)
+			}
+			dead, err := a.DeadCode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range dead {
+				got = append(got, f.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("dead code: %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ==[ type def/func: graphDiff  ]===============================================
+
+// graphDiff is a call graph merged from two revisions of the program,
+// functions and calls present only in one of them are added or removed.
+// Calls are compared by their caller and callee, so moved call sites
+// are not reported as changes.
+type graphDiff struct {
+	Base, Head string // names of compared revisions
+	Graph      *callGraph
+
+	// base is JSON output containing only shown functions,
+	// functions without calls are missing there
+	Partial bool
+
+	AddedFuncs   map[*funcNode]bool
+	RemovedFuncs map[*funcNode]bool
+	AddedCalls   map[*callEdge]bool
+	RemovedCalls map[*callEdge]bool
+}
+
+// diffGraphs merges graphs of two revisions, the graphs are not modified.
+// Functions present in both revisions are taken from the head.
+func diffGraphs(base, head *callGraph, baseName, headName string) *graphDiff {
+	d := &graphDiff{
+		Base:         baseName,
+		Head:         headName,
+		Graph:        &callGraph{Mains: head.Mains},
+		AddedFuncs:   make(map[*funcNode]bool),
+		RemovedFuncs: make(map[*funcNode]bool),
+		AddedCalls:   make(map[*callEdge]bool),
+		RemovedCalls: make(map[*callEdge]bool),
+	}
+	g := d.Graph
+
+	pkgs := make(map[string]bool)
+	for _, p := range head.Packages {
+		pkgs[p.Path] = true
+		g.Packages = append(g.Packages, p)
+	}
+	for _, p := range base.Packages {
+		if !pkgs[p.Path] {
+			g.Packages = append(g.Packages, p)
+		}
+	}
+	sort.Slice(g.Packages, func(i, j int) bool {
+		return g.Packages[i].Path < g.Packages[j].Path
+	})
+
+	// functions are copied, linking the merged graph
+	// must not touch the graphs shared by other renders
+	funcs := make(map[string]*funcNode)
+	inBase := make(map[string]bool)
+	for _, f := range base.Funcs {
+		inBase[f.ID] = true
+	}
+	for _, f := range head.Funcs {
+		nf := *f
+		funcs[f.ID] = &nf
+		if !inBase[f.ID] {
+			d.AddedFuncs[&nf] = true
+		}
+	}
+	for _, f := range base.Funcs {
+		if _, ok := funcs[f.ID]; !ok {
+			nf := *f
+			funcs[f.ID] = &nf
+			d.RemovedFuncs[&nf] = true
+		}
+	}
+	for _, f := range funcs {
+		g.Funcs = append(g.Funcs, f)
+	}
+	sort.Slice(g.Funcs, func(i, j int) bool {
+		return g.Funcs[i].ID < g.Funcs[j].ID
+	})
+	index := make(map[*funcNode]int)
+	for i, f := range g.Funcs {
+		index[f] = i
+	}
+
+	type call struct{ caller, callee string }
+	calls := func(cg *callGraph) map[call]bool {
+		m := make(map[call]bool)
+		for _, e := range cg.Edges {
+			m[call{e.caller.ID, e.callee.ID}] = true
+		}
+		return m
+	}
+	baseCalls, headCalls := calls(base), calls(head)
+	merge := func(e *callEdge) *callEdge {
+		ne := *e
+		ne.Caller = index[funcs[e.caller.ID]]
+		ne.Callee = index[funcs[e.callee.ID]]
+		g.Edges = append(g.Edges, &ne)
+		return &ne
+	}
+	for _, e := range head.Edges {
+		ne := merge(e)
+		if !baseCalls[call{e.caller.ID, e.callee.ID}] {
+			d.AddedCalls[ne] = true
+		}
+	}
+	for _, e := range base.Edges {
+		if !headCalls[call{e.caller.ID, e.callee.ID}] {
+			d.RemovedCalls[merge(e)] = true
+		}
+	}
+
+	g.link()
+
+	logf("diff: %d added and %d removed functions, %d added and %d removed calls",
+		len(d.AddedFuncs), len(d.RemovedFuncs), len(d.AddedCalls), len(d.RemovedCalls))
+
+	return d
+}
+
+// printDiff writes summary of changes as Markdown, so it can be pasted into
+// pull requests. Calls omitted by the filter are not listed, functions are
+// listed if they are declared in the analyzed module or some of their calls
+// changed, only the latter is used for partial base.
+func printDiff(d *graphDiff, filter *edgeFilter) []byte {
+	module := make(map[string]bool)
+	for _, p := range d.Graph.Packages {
+		if p.Module {
+			module[p.Path] = true
+		}
+	}
+
+	// single call of each function, all its call sites are listed
+	calls := func(changed map[*callEdge]bool) []*callEdge {
+		var edges []*callEdge
+		for _, e := range d.Graph.Edges {
+			if changed[e] && !filter.omit(e) {
+				edges = append(edges, e)
+			}
+		}
+		return uniqueCalls(edges)
+	}
+	added, removed := calls(d.AddedCalls), calls(d.RemovedCalls)
+
+	funcs := func(changed map[*funcNode]bool, edges []*callEdge) []*funcNode {
+		listed := make(map[*funcNode]bool)
+		for _, e := range edges {
+			listed[e.caller] = true
+			listed[e.callee] = true
+		}
+		var fns []*funcNode
+		for _, f := range d.Graph.Funcs {
+			if changed[f] && !f.Closure && (module[f.Pkg] && !d.Partial || listed[f]) {
+				fns = append(fns, f)
+			}
+		}
+		return fns
+	}
+	addedFuncs, removedFuncs := funcs(d.AddedFuncs, added), funcs(d.RemovedFuncs, removed)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "### Call graph changes: %s → %s\n\n", d.Base, d.Head)
+	if len(addedFuncs)+len(removedFuncs)+len(added)+len(removed) == 0 {
+		buf.WriteString("No changes.\n")
+		return buf.Bytes()
+	}
+	fmt.Fprintf(&buf, "Functions: %d added, %d removed. Calls: %d added, %d removed.\n",
+		len(addedFuncs), len(removedFuncs), len(added), len(removed))
+
+	printFuncs := func(title string, fns []*funcNode) {
+		if len(fns) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "\n#### %s\n\n", title)
+		for _, f := range fns {
+			fmt.Fprintf(&buf, "- `%s` (%s:%d)\n", f.ShortName(), filepath.Base(f.Pos.Filename), f.Pos.Line)
+		}
+	}
+	printCalls := func(title string, edges []*callEdge) {
+		if len(edges) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "\n#### %s\n\n", title)
+		for _, e := range edges {
+			fmt.Fprintf(&buf, "- `%s` → `%s` at %s\n", e.caller.ShortName(), e.callee.ShortName(), callSites(e, filter))
+		}
+	}
+	printFuncs("Added functions", addedFuncs)
+	printFuncs("Removed functions", removedFuncs)
+	printCalls("Added calls", added)
+	printCalls("Removed calls", removed)
+
+	return buf.Bytes()
+}
+
+// loadJSONGraph reads call graph from output written with -format=json,
+// such graph contains only functions and calls shown in the output.
+func loadJSONGraph(file string) (*callGraph, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var jg jsonGraph
+	if err := json.Unmarshal(data, &jg); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	g := &callGraph{}
+	index := make(map[string]int)
+	pkgs := make(map[string]bool)
+	for _, n := range jg.Nodes {
+		// collapsed cycles are not functions
+		if _, ok := index[n.ID]; ok || n.Package == "" {
+			continue
+		}
+		index[n.ID] = len(g.Funcs)
+		closure := strings.Contains(n.Name, "$")
+		g.Funcs = append(g.Funcs, &funcNode{
+			ID:        n.ID,
+			Name:      n.Name,
+			Pkg:       n.Package,
+			PkgName:   path.Base(n.Package), // not stored, used only for printing
+			Recv:      n.Receiver,
+			Exported:  n.Exported,
+			HasObject: !closure, // not stored, only closures are known by name
+			Closure:   closure,
+			Pos:       n.Position.tokenPosition(),
+		})
+		if !pkgs[n.Package] {
+			pkgs[n.Package] = true
+			g.Packages = append(g.Packages, &graphPackage{
+				Path: n.Package,
+				Name: path.Base(n.Package),
+			})
+		}
+	}
+	sort.Slice(g.Packages, func(i, j int) bool {
+		return g.Packages[i].Path < g.Packages[j].Path
+	})
+	for _, e := range jg.Edges {
+		caller, ok := index[e.Caller]
+		if !ok {
+			continue
+		}
+		callee, ok := index[e.Callee]
+		if !ok {
+			continue
+		}
+		for _, site := range e.Sites {
+			g.Edges = append(g.Edges, &callEdge{
+				Caller: caller,
+				Callee: callee,
+				Kind:   e.Kind,
+				Pos:    site.tokenPosition(),
+			})
+		}
+	}
+	g.link()
+
+	logf("loaded %d functions and %d calls from %s", len(g.Funcs), len(g.Edges), file)
+
+	return g, nil
+}
+
+// relocate rewrites positions in files under one directory to another,
+// it is used for graphs of revisions checked out to temporary worktree.
+func (g *callGraph) relocate(from, to string) {
+	move := func(name string) string {
+		if rel, err := filepath.Rel(from, name); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.Join(to, rel)
+		}
+		return name
+	}
+	for _, f := range g.Funcs {
+		f.Pos.Filename = move(f.Pos.Filename)
+	}
+	for _, e := range g.Edges {
+		e.Pos.Filename = move(e.Pos.Filename)
+	}
+}
+
+// ==[ type def/func: gitWorktree ]==============================================
+
+// gitWorktree is a revision of the current git repository
+// checked out to temporary directory.
+type gitWorktree struct {
+	Rev  string
+	Root string // root of the worktree
+	Top  string // root of the current repository
+	Dir  string // current directory inside the worktree
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkoutRevision adds detached worktree of the revision, it must be
+// removed using Remove when it is not needed anymore.
+func checkoutRevision(rev string) (*gitWorktree, error) {
+	top, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := runGit("", "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	root, err := os.MkdirTemp("", "go-callvis-")
+	if err != nil {
+		return nil, err
+	}
+
+	logf("checking out %s to %s", rev, root)
+
+	if _, err := runGit("", "worktree", "add", "--detach", root, rev); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+	return &gitWorktree{
+		Rev:  rev,
+		Root: root,
+		Top:  top,
+		Dir:  filepath.Join(root, prefix),
+	}, nil
+}
+
+// Remove deletes the worktree.
+func (w *gitWorktree) Remove() {
+	if _, err := runGit("", "worktree", "remove", "--force", w.Root); err != nil {
+		log.Printf("removing worktree failed: %v", err)
+	}
+	os.RemoveAll(w.Root)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testDiffGraph returns graph of calls in module package example.com/a,
+// calls are placed on given lines.
+func testDiffGraph(calls []string, lines []int) *callGraph {
+	g := testCallGraph(calls...)
+	g.Packages = []*graphPackage{{Path: "example.com/a", Name: "a", Module: true}}
+	for _, f := range g.Funcs {
+		f.Pos = token.Position{Filename: "/src/a.go", Line: 1}
+	}
+	for i, e := range g.Edges {
+		e.Pos = token.Position{Filename: "/src/a.go", Line: lines[i]}
+	}
+	return g
+}
+
+func TestDiffGraphs(t *testing.T) {
+	base := testDiffGraph(
+		[]string{"a.Main -> a.A", "a.A -> a.Old", "a.Main -> a.Moved"},
+		[]int{10, 11, 12},
+	)
+	head := testDiffGraph(
+		[]string{"a.Main -> a.A", "a.A -> a.New", "a.Main -> a.Moved", "a.Main -> a.Moved"},
+		[]int{10, 11, 20, 21},
+	)
+	d := diffGraphs(base, head, "main", "working tree")
+
+	names := func(funcs map[*funcNode]bool) map[string]bool {
+		m := make(map[string]bool)
+		for f := range funcs {
+			m[f.ShortName()] = true
+		}
+		return m
+	}
+	calls := func(edges map[*callEdge]bool) map[string]bool {
+		m := make(map[string]bool)
+		for e := range edges {
+			m[e.caller.ShortName()+" -> "+e.callee.ShortName()] = true
+		}
+		return m
+	}
+	if got := names(d.AddedFuncs); len(got) != 1 || !got["a.New"] {
+		t.Errorf("added functions: %v, want a.New", got)
+	}
+	if got := names(d.RemovedFuncs); len(got) != 1 || !got["a.Old"] {
+		t.Errorf("removed functions: %v, want a.Old", got)
+	}
+	// moved call sites are not changes
+	if got := calls(d.AddedCalls); len(got) != 1 || !got["a.A -> a.New"] {
+		t.Errorf("added calls: %v, want a.A -> a.New", got)
+	}
+	if got := calls(d.RemovedCalls); len(got) != 1 || !got["a.A -> a.Old"] {
+		t.Errorf("removed calls: %v, want a.A -> a.Old", got)
+	}
+
+	want := "### Call graph changes: main → working tree\n\n" +
+		"Functions: 1 added, 1 removed. Calls: 1 added, 1 removed.\n\n" +
+		"#### Added functions\n\n- `a.New` (a.go:1)\n\n" +
+		"#### Removed functions\n\n- `a.Old` (a.go:1)\n\n" +
+		"#### Added calls\n\n- `a.A` → `a.New` at a.go:11\n\n" +
+		"#### Removed calls\n\n- `a.A` → `a.Old` at a.go:11\n"
+	if got := string(printDiff(d, &edgeFilter{})); got != want {
+		t.Errorf("printDiff:\n%s\nwant:\n%s", got, want)
+	}
+
+	same := diffGraphs(base, base, "main", "main")
+	if got := string(printDiff(same, &edgeFilter{})); got != "### Call graph changes: main → main\n\nNo changes.\n" {
+		t.Errorf("printDiff of the same graphs:\n%s", got)
+	}
+}
+
+func TestDiffPartialBase(t *testing.T) {
+	// JSON output contains only shown functions, a.Lone is missing
+	file := filepath.Join(t.TempDir(), "base.json")
+	err := os.WriteFile(file, []byte(`{
+  "title": "example.com/a",
+  "nodes": [
+    {"id": "example.com/a.Main", "name": "Main", "package": "example.com/a", "exported": true,
+     "position": {"file": "/src/a.go", "line": 1, "column": 1}, "cluster": "focus"},
+    {"id": "example.com/a.A", "name": "A", "package": "example.com/a", "exported": true,
+     "position": {"file": "/src/a.go", "line": 1, "column": 1}, "cluster": "focus"},
+    {"id": "example.com/a.Main$1", "name": "Main$1", "package": "example.com/a",
+     "position": {"file": "/src/a.go", "line": 1, "column": 1}, "cluster": "focus"}
+  ],
+  "edges": [
+    {"caller": "example.com/a.Main", "callee": "example.com/a.A", "kind": "static",
+     "sites": [{"file": "/src/a.go", "line": 10, "column": 2}]}
+  ],
+  "cluster": null
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := loadJSONGraph(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range base.Funcs {
+		if closure := f.Name == "Main$1"; f.Closure != closure || f.HasObject == closure {
+			t.Errorf("%s loaded with closure %v, has object %v", f.Name, f.Closure, f.HasObject)
+		}
+	}
+
+	head := testDiffGraph([]string{"a.Main -> a.A", "a.A -> a.New"}, []int{10, 12})
+	head.Funcs = append(head.Funcs, &funcNode{
+		ID:      "example.com/a.Lone",
+		Name:    "Lone",
+		Pkg:     "example.com/a",
+		PkgName: "a",
+		Pos:     token.Position{Filename: "/src/a.go", Line: 1},
+	})
+
+	// removed closure is not listed
+	d := diffGraphs(base, head, "base.json", "working tree")
+	want := "### Call graph changes: base.json → working tree\n\n" +
+		"Functions: 2 added, 0 removed. Calls: 1 added, 0 removed.\n\n" +
+		"#### Added functions\n\n- `a.Lone` (a.go:1)\n- `a.New` (a.go:1)\n\n" +
+		"#### Added calls\n\n- `a.A` → `a.New` at a.go:12\n"
+	if got := string(printDiff(d, &edgeFilter{})); got != want {
+		t.Errorf("printDiff:\n%s\nwant:\n%s", got, want)
+	}
+
+	// functions missing in partial base are listed only with changed calls
+	d.Partial = true
+	want = "### Call graph changes: base.json → working tree\n\n" +
+		"Functions: 1 added, 0 removed. Calls: 1 added, 0 removed.\n\n" +
+		"#### Added functions\n\n- `a.New` (a.go:1)\n\n" +
+		"#### Added calls\n\n- `a.A` → `a.New` at a.go:12\n"
+	if got := string(printDiff(d, &edgeFilter{})); got != want {
+		t.Errorf("printDiff of partial base:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -3,12 +3,13 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"go/token"
 	"io"
-	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
 )
 
@@ -41,7 +42,7 @@ const tmplEdge = `{{define "node" -}}
 {{- end}}`
 
 const tmplGraph = `digraph gocallvis {
-    label="{{.Title}}";
+    label="{{.Label}}";
     labeljust="l";
     fontname="Arial";
     fontsize="14";
@@ -56,6 +57,22 @@ const tmplGraph = `digraph gocallvis {
     edge [minlen="{{.Options.minlen}}"]
 
     {{template "cluster" .Cluster}}
+    {{- if .Mains}}
+
+    subgraph "cluster_mains" {
+        label="main packages";
+        style="filled,rounded";
+        fillcolor="white";
+        penwidth="0.5";
+        {{- range $i, $m := .Mains}}
+        {{printf "%q" (printf "main:%d" $i)}} [ label={{printf "%q" $m.Path}} URL={{printf "%q" $m.URL}} tooltip="use as root" shape="box" style="{{if $m.Selected}}filled,bold{{else}}filled{{end}}" fillcolor="{{if $m.Selected}}lightblue{{else}}white{{end}}" ];
+        {{- end}}
+    }
+    {{- end}}
+    {{- if .DeadURL}}
+
+    "toggle:dead" [ label="{{if .Dead}}hide{{else}}show{{end}} unreachable functions" URL={{printf "%q" .DeadURL}} tooltip="functions unreachable from entry points" shape="box" style="filled,rounded" fillcolor="{{if .Dead}}#e0e0e0{{else}}white{{end}}" ];
+    {{- end}}
 
     {{- range .Edges}}
     {{template "edge" .}}
@@ -66,6 +83,7 @@ const tmplGraph = `digraph gocallvis {
 // ==[ type def/func: dotCluster ]===============================================
 type dotCluster struct {
 	ID       string
+	Kind     string // package or type, empty for the focus cluster
 	Clusters map[string]*dotCluster
 	Nodes    []*dotNode
 	Attrs    dotAttrs
@@ -83,10 +101,31 @@ func (c *dotCluster) String() string {
 	return fmt.Sprintf("cluster_%s", c.ID)
 }
 
+// sortedClusters returns sub-clusters of c sorted by their ID.
+func (c *dotCluster) sortedClusters() []*dotCluster {
+	var clusters []*dotCluster
+	for _, sub := range c.Clusters {
+		clusters = append(clusters, sub)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].ID < clusters[j].ID
+	})
+	return clusters
+}
+
 // ==[ type def/func: dotNode    ]===============================================
 type dotNode struct {
 	ID    string
 	Attrs dotAttrs
+
+	// details of the function used by other output formats
+	Name     string
+	Pkg      string
+	Recv     string
+	Exported bool
+	Focused  bool
+	Dead     bool
+	Pos      token.Position
 }
 
 func (n *dotNode) String() string {
@@ -98,6 +137,19 @@ type dotEdge struct {
 	From  *dotNode
 	To    *dotNode
 	Attrs dotAttrs
+
+	// details of the call used by other output formats
+	Kind  callKind
+	Sites []token.Position
+}
+
+// ==[ type def/func: dotMain    ]===============================================
+
+// dotMain is a main package which can be selected as root of the graph.
+type dotMain struct {
+	Path     string
+	URL      string
+	Selected bool
 }
 
 // ==[ type def/func: dotAttrs   ]===============================================
@@ -122,6 +174,10 @@ func (p dotAttrs) Lines() string {
 // ==[ type def/func: dotGraph   ]===============================================
 type dotGraph struct {
 	Title   string
+	Errors  []string
+	Mains   []dotMain
+	Dead    bool
+	DeadURL string // toggles showing unreachable functions
 	Minlen  uint
 	Attrs   dotAttrs
 	Cluster *dotCluster
@@ -130,6 +186,60 @@ type dotGraph struct {
 	Options map[string]string
 }
 
+// Label returns graph label with title followed
+// by errors of packages, each on separate line.
+func (g *dotGraph) Label() string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
+	label := escape(g.Title)
+	if len(g.Errors) > 0 {
+		label += fmt.Sprintf(`\l\l%d packages contain errors:\l`, len(g.Errors))
+		for _, e := range g.Errors {
+			label += escape(e) + `\l`
+		}
+	}
+	return label
+}
+
+// sortedNodes returns nodes of c sorted by their ID.
+func (c *dotCluster) sortedNodes() []*dotNode {
+	nodes := append([]*dotNode(nil), c.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// allNodes returns nodes of g including nodes in clusters sorted by their ID.
+func (g *dotGraph) allNodes() []*dotNode {
+	nodes := append([]*dotNode(nil), g.Nodes...)
+	var walk func(c *dotCluster)
+	walk = func(c *dotCluster) {
+		nodes = append(nodes, c.Nodes...)
+		for _, sub := range c.Clusters {
+			walk(sub)
+		}
+	}
+	if g.Cluster != nil {
+		walk(g.Cluster)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// sortedEdges returns edges of g sorted by caller and callee.
+func (g *dotGraph) sortedEdges() []*dotEdge {
+	edges := append([]*dotEdge(nil), g.Edges...)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From.ID != edges[j].From.ID {
+			return edges[i].From.ID < edges[j].From.ID
+		}
+		return edges[i].To.ID < edges[j].To.ID
+	})
+	return edges
+}
+
 func (g *dotGraph) WriteDot(w io.Writer) error {
 	t := template.New("dot")
 	for _, s := range []string{tmplCluster, tmplNode, tmplEdge, tmplGraph} {
@@ -145,6 +255,12 @@ func (g *dotGraph) WriteDot(w io.Writer) error {
 	return err
 }
 
+// renderers of SVG images selected by -renderer flag
+const (
+	rendererGraphviz = "graphviz"
+	rendererBuiltin  = "builtin"
+)
+
 func dotToImage(outfname string, format string, dot []byte) (string, error) {
 	if *graphvizFlag {
 		return runDotToImageCallSystemGraphviz(outfname, format, dot)
@@ -153,25 +269,39 @@ func dotToImage(outfname string, format string, dot []byte) (string, error) {
 	return runDotToImage(outfname, format, dot)
 }
 
+// imageFilename returns filename for image output, when outfname is empty
+// a unique temporary file is created so concurrent requests do not collide.
+func imageFilename(outfname string, format string) (string, error) {
+	if outfname != "" {
+		return fmt.Sprintf("%s.%s", outfname, format), nil
+	}
+	f, err := os.CreateTemp("", fmt.Sprintf("go-callvis_export_*.%s", format))
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
 // location of dot executable for converting from .dot to .svg
 // it's usually at: /usr/bin/dot
-var dotSystemBinary string
+var (
+	dotSystemBinary     string
+	dotSystemBinaryErr  error
+	dotSystemBinaryOnce sync.Once
+)
 
 // runDotToImageCallSystemGraphviz generates a SVG using the 'dot' utility, returning the filepath
 func runDotToImageCallSystemGraphviz(outfname string, format string, dot []byte) (string, error) {
-	if dotSystemBinary == "" {
-		dot, err := exec.LookPath("dot")
-		if err != nil {
-			log.Fatalln("unable to find program 'dot', please install it or check your PATH")
-		}
-		dotSystemBinary = dot
+	dotSystemBinaryOnce.Do(func() {
+		dotSystemBinary, dotSystemBinaryErr = exec.LookPath("dot")
+	})
+	if dotSystemBinaryErr != nil {
+		return "", fmt.Errorf("unable to find program 'dot', please install it or check your PATH")
 	}
 
-	var img string
-	if outfname == "" {
-		img = filepath.Join(os.TempDir(), fmt.Sprintf("go-callvis_export.%s", format))
-	} else {
-		img = fmt.Sprintf("%s.%s", outfname, format)
+	img, err := imageFilename(outfname, format)
+	if err != nil {
+		return "", err
 	}
 	cmd := exec.Command(dotSystemBinary, fmt.Sprintf("-T%s", format), "-o", img)
 	cmd.Stdin = bytes.NewReader(dot)
@@ -4,36 +4,44 @@
 package main
 
 import (
-    "fmt"
-    "log"
-    "os"
-    "path/filepath"
+	"log"
+	"sync"
 
-    "github.com/goccy/go-graphviz"
+	"github.com/goccy/go-graphviz"
 )
 
+// defaultRenderer returns renderer used unless -renderer is given,
+// graphviz library is always linked in.
+func defaultRenderer() string {
+	return rendererGraphviz
+}
+
+// graphvizMu serializes rendering, graphviz library is not safe for concurrent use
+var graphvizMu sync.Mutex
+
 func runDotToImage(outfname string, format string, dot []byte) (string, error) {
-    g := graphviz.New()
-    graph, err := graphviz.ParseBytes(dot)
-    if err != nil {
-        return "", err
-    }
-    defer func() {
-        if err := graph.Close(); err != nil {
-            log.Printf("error closing graph: %v", err)
-        }
-        if err := g.Close(); err != nil {
-            log.Printf("error closing graphviz: %v", err)
-        }
-    }()
-    var img string
-    if outfname == "" {
-        img = filepath.Join(os.TempDir(), fmt.Sprintf("go-callvis_export.%s", format))
-    } else {
-        img = fmt.Sprintf("%s.%s", outfname, format)
-    }
-    if err := g.RenderFilename(graph, graphviz.Format(format), img); err != nil {
-        return "", err
-    }
-    return img, nil
+	graphvizMu.Lock()
+	defer graphvizMu.Unlock()
+
+	g := graphviz.New()
+	graph, err := graphviz.ParseBytes(dot)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := graph.Close(); err != nil {
+			log.Printf("error closing graph: %v", err)
+		}
+		if err := g.Close(); err != nil {
+			log.Printf("error closing graphviz: %v", err)
+		}
+	}()
+	img, err := imageFilename(outfname, format)
+	if err != nil {
+		return "", err
+	}
+	if err := g.RenderFilename(graph, graphviz.Format(format), img); err != nil {
+		return "", err
+	}
+	return img, nil
 }
@@ -3,6 +3,17 @@
 
 package main
 
+import "os/exec"
+
+// defaultRenderer returns renderer used unless -renderer is given,
+// static binaries without Graphviz write SVG using built-in layout.
+func defaultRenderer() string {
+	if _, err := exec.LookPath("dot"); err != nil {
+		return rendererBuiltin
+	}
+	return rendererGraphviz
+}
+
 func runDotToImage(outfname string, format string, dot []byte) (string, error) {
-    return runDotToImageCallSystemGraphviz(outfname, format, dot)
+	return runDotToImageCallSystemGraphviz(outfname, format, dot)
 }
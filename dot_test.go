@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+// testDotGraph returns graph with focused package main calling package pkg,
+// edges carry styles of overlays which must not change the kind of calls.
+func testDotGraph() *dotGraph {
+	main := &dotNode{ID: "example.com/cmd.main", Name: "main", Pkg: "example.com/cmd", Focused: true,
+		Attrs: dotAttrs{"label": "main", "fillcolor": "lightblue"}}
+	run := &dotNode{ID: "example.com/pkg.Run", Name: "Run", Pkg: "example.com/pkg", Exported: true,
+		Attrs: dotAttrs{"label": "pkg\nRun", "fillcolor": "moccasin"}}
+	do := &dotNode{ID: "(*example.com/pkg.T).Do", Name: "(*T).Do", Pkg: "example.com/pkg", Recv: "*example.com/pkg.T", Exported: true,
+		Attrs: dotAttrs{"label": "Do", "fillcolor": "moccasin"}}
+
+	typ := NewDotCluster("*example.com/pkg.T")
+	typ.Kind = "type"
+	typ.Attrs["label"] = "(*T)"
+	typ.Nodes = []*dotNode{do}
+	pkg := NewDotCluster("example.com/pkg")
+	pkg.Kind = "package"
+	pkg.Attrs["label"] = "pkg"
+	pkg.Nodes = []*dotNode{run}
+	pkg.Clusters[typ.ID] = typ
+	focus := NewDotCluster("focus")
+	focus.Attrs["label"] = "main"
+	focus.Nodes = []*dotNode{main}
+	focus.Clusters[pkg.ID] = pkg
+
+	return &dotGraph{
+		Title:   "example.com/cmd",
+		Cluster: focus,
+		Minlen:  2,
+		Options: map[string]string{"rankdir": "LR", "nodesep": "0.35", "nodestyle": "filled,rounded"},
+		Edges: []*dotEdge{
+			// static call never executed by tests is dashed
+			{From: main, To: run, Kind: callKindStatic, Attrs: dotAttrs{"style": "dashed", "color": "#a0a0a0"}},
+			// dynamic call violating a rule is bold
+			{From: run, To: do, Kind: callKindDynamic, Attrs: dotAttrs{"style": "bold", "color": "red"}},
+			{From: main, To: do, Kind: callKindGo, Attrs: dotAttrs{}},
+		},
+	}
+}
+
+func TestDotGraphSortedEdges(t *testing.T) {
+	var got []string
+	for _, e := range testDotGraph().sortedEdges() {
+		got = append(got, e.From.ID+" -> "+e.To.ID)
+	}
+	want := []string{
+		"example.com/cmd.main -> (*example.com/pkg.T).Do",
+		"example.com/cmd.main -> example.com/pkg.Run",
+		"example.com/pkg.Run -> (*example.com/pkg.T).Do",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("sortedEdges() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sortedEdges()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// ==[ type def/func: GEXF       ]===============================================
+type gexfDoc struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Meta    gexfMeta  `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	Creator     string `xml:"creator"`
+	Description string `xml:"description,omitempty"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string          `xml:"id,attr"`
+	Label     string          `xml:"label,attr"`
+	AttValues []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID        string          `xml:"id,attr"`
+	Source    string          `xml:"source,attr"`
+	Target    string          `xml:"target,attr"`
+	Weight    int             `xml:"weight,attr"`
+	AttValues []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttrValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func (g *dotGraph) WriteGEXF(w io.Writer) error {
+	doc := gexfDoc{
+		Xmlns:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta: gexfMeta{
+			Creator:     "go-callvis " + version,
+			Description: g.Title,
+		},
+		Graph: gexfGraph{
+			DefaultEdgeType: "directed",
+			Attributes: []gexfAttributes{
+				{
+					Class: "node",
+					Attributes: []gexfAttribute{
+						{"package", "package", "string"},
+						{"type", "type", "string"},
+						{"exported", "exported", "boolean"},
+						{"std", "std", "boolean"},
+						{"focus", "focus", "boolean"},
+					},
+				},
+				{
+					Class: "edge",
+					Attributes: []gexfAttribute{
+						{"kind", "kind", "string"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, n := range g.allNodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:    n.ID,
+			Label: n.Name,
+			AttValues: []gexfAttrValue{
+				{"package", n.Pkg},
+				{"type", n.Recv},
+				{"exported", fmt.Sprint(n.Exported)},
+				{"std", fmt.Sprint(isStdPkgPath(n.Pkg))},
+				{"focus", fmt.Sprint(n.Focused)},
+			},
+		})
+	}
+
+	for i, e := range g.sortedEdges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:     fmt.Sprint(i),
+			Source: e.From.ID,
+			Target: e.To.ID,
+			Weight: len(e.Sites),
+			AttValues: []gexfAttrValue{
+				{"kind", string(e.Kind)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"go/token"
+	"testing"
+)
+
+func TestWriteGEXF(t *testing.T) {
+	g := testDotGraph()
+	g.Edges[0].Sites = []token.Position{{Line: 7}, {Line: 9}}
+
+	var buf bytes.Buffer
+	if err := g.WriteGEXF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc gexfDoc
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if doc.Version != "1.3" || doc.Meta.Description != "example.com/cmd" {
+		t.Errorf("version %q, description %q, want 1.3 and example.com/cmd", doc.Version, doc.Meta.Description)
+	}
+
+	declared := make(map[string]map[string]bool)
+	for _, a := range doc.Graph.Attributes {
+		declared[a.Class] = make(map[string]bool)
+		for _, attr := range a.Attributes {
+			declared[a.Class][attr.ID] = true
+		}
+	}
+	values := func(class string, vs []gexfAttrValue) map[string]string {
+		m := make(map[string]string)
+		for _, v := range vs {
+			if !declared[class][v.For] {
+				t.Errorf("%s value refers to undeclared attribute %q", class, v.For)
+			}
+			m[v.For] = v.Value
+		}
+		return m
+	}
+
+	nodes := make(map[string]gexfNode)
+	for _, n := range doc.Graph.Nodes {
+		nodes[n.ID] = n
+	}
+	for _, tt := range []struct {
+		id    string
+		label string
+		key   string
+		value string
+	}{
+		{"example.com/cmd.main", "main", "focus", "true"},
+		{"example.com/pkg.Run", "Run", "package", "example.com/pkg"},
+		{"(*example.com/pkg.T).Do", "(*T).Do", "type", "*example.com/pkg.T"},
+	} {
+		n, ok := nodes[tt.id]
+		if !ok {
+			t.Errorf("node %s is missing", tt.id)
+			continue
+		}
+		if n.Label != tt.label {
+			t.Errorf("node %s label = %q, want %q", tt.id, n.Label, tt.label)
+		}
+		if v := values("node", n.AttValues)[tt.key]; v != tt.value {
+			t.Errorf("node %s %s = %q, want %q", tt.id, tt.key, v, tt.value)
+		}
+	}
+	if len(nodes) != 3 {
+		t.Errorf("got %d nodes, want 3", len(nodes))
+	}
+
+	for i, want := range []struct {
+		source, target string
+		kind           string
+		weight         int
+	}{
+		{"example.com/cmd.main", "(*example.com/pkg.T).Do", "go", 0},
+		{"example.com/cmd.main", "example.com/pkg.Run", "static", 2},
+		{"example.com/pkg.Run", "(*example.com/pkg.T).Do", "dynamic", 0},
+	} {
+		if i >= len(doc.Graph.Edges) {
+			t.Fatalf("got %d edges, want 3", len(doc.Graph.Edges))
+		}
+		e := doc.Graph.Edges[i]
+		kind := values("edge", e.AttValues)["kind"]
+		if e.Source != want.source || e.Target != want.target || kind != want.kind || e.Weight != want.weight {
+			t.Errorf("edges[%d] = %s -> %s %s %d, want %+v", i, e.Source, e.Target, kind, e.Weight, want)
+		}
+	}
+	ids := make(map[string]bool)
+	for _, e := range doc.Graph.Edges {
+		if ids[e.ID] {
+			t.Errorf("duplicate edge id %q", e.ID)
+		}
+		ids[e.ID] = true
+	}
+}
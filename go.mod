@@ -6,6 +6,7 @@ toolchain go1.23.1
 
 require (
 	github.com/goccy/go-graphviz v0.1.2
+	github.com/google/pprof v0.0.0-20241210010833-40e02aabc2ad
 	github.com/pkg/browser v0.0.0-20240102092130-5ac0b6a4141c
 	golang.org/x/tools v0.28.0
 )
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// ==[ type def/func: callGraph  ]===============================================
+
+// callGraph is a call graph detached from the SSA program, it holds
+// everything needed for rendering, so it can be stored in the cache.
+type callGraph struct {
+	Packages []*graphPackage
+	Mains    []string
+	Funcs    []*funcNode
+	Edges    []*callEdge
+}
+
+type graphPackage struct {
+	Path    string
+	Name    string
+	Initial bool
+	Module  bool // declared in the analyzed module
+	Errors  []string
+}
+
+type funcNode struct {
+	ID        string // full name of the function
+	Name      string // name relative to its package
+	Pkg       string
+	PkgName   string
+	Recv      string // receiver type of the function or its enclosing function
+	Exported  bool
+	HasObject bool // declared in source, closures and synthetic functions have no types object
+	Closure   bool
+	Origin    string // ID of generic function the function is instantiated from
+	Pos       token.Position
+	End       token.Position // end of function body, unknown for synthetic functions
+	Signature string
+
+	in, out []*callEdge
+}
+
+type callEdge struct {
+	Caller, Callee int // indices into callGraph.Funcs
+	Kind           callKind
+	Pos            token.Position
+
+	caller, callee *funcNode
+}
+
+func (f *funcNode) String() string {
+	return f.ID
+}
+
+// ShortName returns name of the function qualified by its package name,
+// e.g. (*pkg.Type).Method instead of (*example.com/pkg.Type).Method.
+func (f *funcNode) ShortName() string {
+	if rest, ok := strings.CutPrefix(f.Name, "(*"); ok {
+		return "(*" + f.PkgName + "." + rest
+	}
+	if rest, ok := strings.CutPrefix(f.Name, "("); ok {
+		return "(" + f.PkgName + "." + rest
+	}
+	return f.PkgName + "." + f.Name
+}
+
+func (e *callEdge) String() string {
+	return fmt.Sprintf("%s --> %s", e.caller, e.callee)
+}
+
+// newCallGraph converts the call graph of the program,
+// synthetic calls are omitted. Broken packages are included
+// with their errors, even if they are not part of the program.
+func newCallGraph(prog *ssa.Program, pkgs []*ssa.Package, mains []*ssa.Package, module map[string]bool, broken []*graphPackage, cg *callgraph.Graph) *callGraph {
+	g := &callGraph{}
+
+	initial := make(map[*ssa.Package]bool)
+	for _, p := range pkgs {
+		initial[p] = true
+	}
+	errs := make(map[string]*graphPackage)
+	for _, p := range broken {
+		errs[p.Path] = p
+	}
+	for _, p := range prog.AllPackages() {
+		gp := &graphPackage{
+			Path:    p.Pkg.Path(),
+			Name:    p.Pkg.Name(),
+			Initial: initial[p],
+			Module:  module[p.Pkg.Path()],
+		}
+		if b, ok := errs[gp.Path]; ok {
+			gp.Errors = b.Errors
+			delete(errs, gp.Path)
+		}
+		g.Packages = append(g.Packages, gp)
+	}
+	for _, p := range broken {
+		if _, ok := errs[p.Path]; ok {
+			g.Packages = append(g.Packages, p)
+		}
+	}
+	sort.Slice(g.Packages, func(i, j int) bool {
+		return g.Packages[i].Path < g.Packages[j].Path
+	})
+	for _, p := range mains {
+		g.Mains = append(g.Mains, p.Pkg.Path())
+	}
+
+	var nodes []*callgraph.Node
+	for fn, n := range cg.Nodes {
+		if fn != nil && fn.Pkg != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Func.String() < nodes[j].Func.String()
+	})
+
+	index := make(map[*callgraph.Node]int)
+	for _, n := range nodes {
+		fn := n.Func
+		f := &funcNode{
+			ID:        fn.String(),
+			Name:      fn.RelString(fn.Pkg.Pkg),
+			Pkg:       fn.Pkg.Pkg.Path(),
+			PkgName:   fn.Pkg.Pkg.Name(),
+			Exported:  fn.Object() != nil && fn.Object().Exported(),
+			HasObject: fn.Object() != nil,
+			Closure:   fn.Parent() != nil,
+			Pos:       prog.Fset.Position(fn.Pos()),
+			Signature: fn.Signature.String(),
+		}
+		sign := fn.Signature
+		if fn.Parent() != nil {
+			sign = fn.Parent().Signature
+		}
+		if recv := sign.Recv(); recv != nil {
+			f.Recv = recv.Type().String()
+		}
+		if syntax := fn.Syntax(); syntax != nil {
+			f.End = prog.Fset.Position(syntax.End())
+		}
+		if origin := fn.Origin(); origin != nil {
+			f.Origin = origin.String()
+		}
+		index[n] = len(g.Funcs)
+		g.Funcs = append(g.Funcs, f)
+	}
+
+	for _, n := range nodes {
+		for _, edge := range n.Out {
+			if isSynthetic(edge) {
+				continue
+			}
+			kind := callKindStatic
+			// dynamic call
+			if edge.Site != nil && edge.Site.Common().StaticCallee() == nil {
+				kind = callKindDynamic
+			}
+			// go & defer calls
+			switch edge.Site.(type) {
+			case *ssa.Go:
+				kind = callKindGo
+			case *ssa.Defer:
+				kind = callKindDefer
+			}
+			g.Edges = append(g.Edges, &callEdge{
+				Caller: index[edge.Caller],
+				Callee: index[edge.Callee],
+				Kind:   kind,
+				Pos:    prog.Fset.Position(edge.Pos()),
+			})
+		}
+	}
+
+	g.link()
+	return g
+}
+
+// findFunc returns function with given full or short name,
+// nil is returned if there is no such function.
+func findFunc(g *callGraph, name string) (*funcNode, error) {
+	var found []*funcNode
+	for _, f := range g.Funcs {
+		if f.ID == name {
+			return f, nil
+		}
+		if f.ShortName() == name {
+			found = append(found, f)
+		}
+	}
+	if len(found) > 1 {
+		for _, f := range found {
+			fmt.Fprintf(os.Stderr, " - %s\n", f)
+		}
+		return nil, fmt.Errorf("found multiple functions with name: %v", name)
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	return nil, nil
+}
+
+// withCalls returns copy of the graph with additional calls between its
+// functions, the graph itself is not modified.
+func (g *callGraph) withCalls(calls []*callEdge) *callGraph {
+	ng := &callGraph{
+		Packages: g.Packages,
+		Mains:    g.Mains,
+	}
+	for _, f := range g.Funcs {
+		nf := *f
+		ng.Funcs = append(ng.Funcs, &nf)
+	}
+	for _, e := range append(g.Edges, calls...) {
+		ne := *e
+		ng.Edges = append(ng.Edges, &ne)
+	}
+	ng.link()
+	return ng
+}
+
+// link resolves edges to functions, it must be called
+// after the graph is decoded from the cache.
+func (g *callGraph) link() {
+	for _, f := range g.Funcs {
+		f.in, f.out = nil, nil
+	}
+	for _, e := range g.Edges {
+		e.caller = g.Funcs[e.Caller]
+		e.callee = g.Funcs[e.Callee]
+		e.caller.out = append(e.caller.out, e)
+		e.callee.in = append(e.callee.in, e)
+	}
+}
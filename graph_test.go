@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testCallGraph returns graph of calls written as "pkg.F -> pkg.G",
+// functions are placed in packages under example.com.
+func testCallGraph(calls ...string) *callGraph {
+	g := new(callGraph)
+	index := make(map[string]int)
+	node := func(name string) int {
+		if i, ok := index[name]; ok {
+			return i
+		}
+		pkg, fn, _ := strings.Cut(name, ".")
+		index[name] = len(g.Funcs)
+		g.Funcs = append(g.Funcs, &funcNode{
+			ID:       "example.com/" + name,
+			Name:     fn,
+			Pkg:      "example.com/" + pkg,
+			PkgName:  pkg,
+			Exported: true,
+		})
+		return index[name]
+	}
+	for _, c := range calls {
+		caller, callee, _ := strings.Cut(c, " -> ")
+		g.Edges = append(g.Edges, &callEdge{Caller: node(caller), Callee: node(callee)})
+	}
+	g.link()
+	return g
+}
+
+// testFunc returns function of the graph by name.
+func testFunc(t *testing.T, g *callGraph, name string) *funcNode {
+	t.Helper()
+	for _, f := range g.Funcs {
+		if f.ID == "example.com/"+name {
+			return f
+		}
+	}
+	t.Fatalf("function %s not found", name)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// ==[ type def/func: GraphML    ]===============================================
+type graphmlDoc struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   graphmlGraph `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (g *dotGraph) WriteGraphML(w io.Writer) error {
+	doc := graphmlDoc{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{"label", "node", "label", "string"},
+			{"package", "node", "package", "string"},
+			{"type", "node", "type", "string"},
+			{"exported", "node", "exported", "boolean"},
+			{"std", "node", "std", "boolean"},
+			{"focus", "node", "focus", "boolean"},
+			{"kind", "edge", "kind", "string"},
+			{"weight", "edge", "weight", "int"},
+		},
+		Graph: graphmlGraph{
+			ID:          "gocallvis",
+			EdgeDefault: "directed",
+		},
+	}
+
+	for _, n := range g.allNodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{
+			ID: n.ID,
+			Data: []graphmlData{
+				{"label", n.Name},
+				{"package", n.Pkg},
+				{"type", n.Recv},
+				{"exported", fmt.Sprint(n.Exported)},
+				{"std", fmt.Sprint(isStdPkgPath(n.Pkg))},
+				{"focus", fmt.Sprint(n.Focused)},
+			},
+		})
+	}
+
+	for _, e := range g.sortedEdges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{
+			Source: e.From.ID,
+			Target: e.To.ID,
+			Data: []graphmlData{
+				{"kind", string(e.Kind)},
+				{"weight", fmt.Sprint(len(e.Sites))},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
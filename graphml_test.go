@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestWriteGraphML(t *testing.T) {
+	g := testDotGraph()
+	g.Edges[0].Sites = []token.Position{{Line: 7}, {Line: 9}}
+
+	var buf bytes.Buffer
+	if err := g.WriteGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("output does not start with XML header:\n%s", buf.String())
+	}
+	var doc graphmlDoc
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if doc.Graph.EdgeDefault != "directed" {
+		t.Errorf("edgedefault = %q, want directed", doc.Graph.EdgeDefault)
+	}
+
+	keys := make(map[string]bool)
+	for _, k := range doc.Keys {
+		keys[k.ID] = true
+	}
+	data := func(d []graphmlData) map[string]string {
+		m := make(map[string]string)
+		for _, v := range d {
+			if !keys[v.Key] {
+				t.Errorf("data refers to undeclared key %q", v.Key)
+			}
+			m[v.Key] = v.Value
+		}
+		return m
+	}
+
+	nodes := make(map[string]map[string]string)
+	for _, n := range doc.Graph.Nodes {
+		nodes[n.ID] = data(n.Data)
+	}
+	for _, tt := range []struct {
+		id    string
+		key   string
+		value string
+	}{
+		{"example.com/cmd.main", "label", "main"},
+		{"example.com/cmd.main", "focus", "true"},
+		{"example.com/cmd.main", "exported", "false"},
+		{"example.com/pkg.Run", "package", "example.com/pkg"},
+		{"example.com/pkg.Run", "std", "false"},
+		{"(*example.com/pkg.T).Do", "type", "*example.com/pkg.T"},
+		{"(*example.com/pkg.T).Do", "exported", "true"},
+	} {
+		n, ok := nodes[tt.id]
+		if !ok {
+			t.Errorf("node %s is missing", tt.id)
+			continue
+		}
+		if n[tt.key] != tt.value {
+			t.Errorf("node %s %s = %q, want %q", tt.id, tt.key, n[tt.key], tt.value)
+		}
+	}
+	if len(nodes) != 3 {
+		t.Errorf("got %d nodes, want 3", len(nodes))
+	}
+
+	want := []string{
+		"example.com/cmd.main -> (*example.com/pkg.T).Do go 0",
+		"example.com/cmd.main -> example.com/pkg.Run static 2",
+		"example.com/pkg.Run -> (*example.com/pkg.T).Do dynamic 0",
+	}
+	var got []string
+	for _, e := range doc.Graph.Edges {
+		d := data(e.Data)
+		got = append(got, e.Source+" -> "+e.Target+" "+d["kind"]+" "+d["weight"])
+	}
+	if len(got) != len(want) {
+		t.Fatalf("edges = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("edges[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -4,11 +4,18 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 )
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" && !strings.HasSuffix(r.URL.Path, ".svg") {
+// graphHandler renders the call graph with options given by HTTP parameters.
+type graphHandler struct {
+	overlays renderOverlays
+}
+
+func (h *graphHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/path" && !strings.HasSuffix(r.URL.Path, ".svg") {
 		http.NotFound(w, r)
 		return
 	}
@@ -17,52 +24,90 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	logf(" => handling request:  %v", r.URL)
 	logf("----------------------")
 
+	a := Analysis.Load()
+	if a == nil {
+		serveProgress(w, r)
+		return
+	}
+
 	// set up cmdline default for analysis
-	Analysis.OptsSetup()
+	opts := a.OptsSetup(h.overlays)
 
 	// .. and allow overriding by HTTP params
-	Analysis.OverrideByHTTP(r)
+	opts.OverrideByHTTP(r)
 
-	var img string
-	if img = Analysis.FindCachedImg(); img != "" {
-		log.Println("serving cached file:", img)
-		http.ServeFile(w, r, img)
+	if r.URL.Path == "/path" && (opts.from == "" || opts.to == "") {
+		http.Error(w, "path requires from and to parameters", http.StatusBadRequest)
 		return
 	}
 
+	// link for toggling unreachable functions keeps other parameters
+	query := r.URL.Query()
+	query.Del("refresh")
+	query.Set("dead", strconv.FormatBool(!opts.dead))
+	opts.deadURL = r.URL.Path + "?" + query.Encode()
+
 	// Convert list-style args to []string
-	if e := Analysis.ProcessListArgs(); e != nil {
+	if e := opts.ProcessListArgs(); e != nil {
 		http.Error(w, "invalid parameters", http.StatusBadRequest)
 		return
 	}
 
-	output, err := Analysis.Render()
+	key := a.cacheKey(opts)
+
+	var img string
+	if img = a.FindCachedImg(opts, key); img != "" {
+		log.Println("serving cached file:", img)
+		serveImage(w, r, img, opts.format)
+		return
+	}
+
+	output, err := a.Render(opts)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("rendering failed: %v", err.Error()), http.StatusInternalServerError)
 		return
 	}
 
-	if r.Form.Get("format") == "dot" {
+	if opts.format == "dot" {
 		log.Println("writing dot output")
 		fmt.Fprint(w, string(output))
 		return
 	}
 
-	log.Printf("converting dot to %s\n", *outputFormat)
+	if opts.format == textFormat {
+		log.Println("writing text output")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(output)
+		return
+	}
+
+	if f, ok := opts.graphFormat(); ok {
+		log.Printf("writing %s output", opts.format)
+		if opts.format == "svg" {
+			serveSVG(w, output)
+			return
+		}
+		w.Header().Set("Content-Type", f.contentType)
+		w.Write(output)
+		return
+	}
+
+	log.Printf("converting dot to %s\n", opts.format)
 
-	img, err = dotToImage("", *outputFormat, output)
+	img, err = dotToImage("", opts.format, output)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer os.Remove(img)
 
-	err = Analysis.CacheImg(img)
+	err = a.CacheImg(opts, key, img)
 	if err != nil {
 		http.Error(w, "cache img error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	log.Println("serving file:", img)
-	http.ServeFile(w, r, img)
+	serveImage(w, r, img, opts.format)
 }
 
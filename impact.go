@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ==[ type def/func: codeChanges ]==============================================
+
+// codeChanges holds changed lines of files given by absolute path.
+type codeChanges struct {
+	Source string // diff range or list of files
+
+	files map[string][]lineRange
+}
+
+// lineRange is inclusive range of lines.
+type lineRange struct {
+	From, To int
+}
+
+// changedFiles returns changes of whole files.
+func changedFiles(files []string) (*codeChanges, error) {
+	c := &codeChanges{
+		Source: strings.Join(files, ","),
+		files:  make(map[string][]lineRange),
+	}
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, err
+		}
+		c.files[abs] = []lineRange{{1, math.MaxInt}}
+	}
+	return c, nil
+}
+
+// gitChanges returns lines changed by git diff of the revision or range,
+// e.g. HEAD compares working tree with the last commit.
+func gitChanges(rev string) (*codeChanges, error) {
+	top, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	out, err := runGit("", "diff", "-U0", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", rev, "--", "*.go")
+	if err != nil {
+		return nil, err
+	}
+	c := &codeChanges{
+		Source: rev,
+		files:  parseDiff(top, out),
+	}
+
+	logf("git diff %s changed %d files", rev, len(c.files))
+
+	return c, nil
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// parseDiff returns changed lines of new files in unified diff without context,
+// deletion is a change of lines around it.
+func parseDiff(top string, diff string) map[string][]lineRange {
+	files := make(map[string][]lineRange)
+	var file string
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "+++ "); ok {
+			file = ""
+			if name, ok := strings.CutPrefix(name, "b/"); ok {
+				file = filepath.Join(top, filepath.FromSlash(name))
+			}
+			continue
+		}
+		m := hunkHeader.FindStringSubmatch(line)
+		if m == nil || file == "" {
+			continue
+		}
+		start, _ := strconv.Atoi(m[1])
+		count := 1
+		if m[2] != "" {
+			count, _ = strconv.Atoi(m[2])
+		}
+		r := lineRange{start, start + count - 1}
+		if count == 0 {
+			r = lineRange{max(start, 1), start + 1}
+		}
+		files[file] = append(files[file], r)
+	}
+	return files
+}
+
+// changed reports whether body of the function contains changed line.
+func (c *codeChanges) changed(f *funcNode) bool {
+	end := f.End.Line
+	if end == 0 {
+		end = f.Pos.Line
+	}
+	for _, r := range c.files[f.Pos.Filename] {
+		if r.From <= end && f.Pos.Line <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// ==[ type def/func: impactSet  ]===============================================
+
+// impactSet holds changed functions and functions calling them transitively.
+type impactSet struct {
+	Source   string // diff range or list of files
+	Changed  map[*funcNode]bool
+	Affected map[*funcNode]bool // including changed functions
+	Calls    map[*callEdge]bool // calls leading to changed functions
+}
+
+// impact walks the call graph backwards from changed functions,
+// calls omitted by the filter are not followed.
+func (c *codeChanges) impact(cg *callGraph, filter *edgeFilter) *impactSet {
+	s := &impactSet{
+		Source:   c.Source,
+		Changed:  make(map[*funcNode]bool),
+		Affected: make(map[*funcNode]bool),
+		Calls:    make(map[*callEdge]bool),
+	}
+	var queue []*funcNode
+	for _, f := range cg.Funcs {
+		if c.changed(f) {
+			s.Changed[f] = true
+			s.Affected[f] = true
+			queue = append(queue, f)
+		}
+	}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		for _, e := range f.in {
+			if filter.omit(e) {
+				continue
+			}
+			s.Calls[e] = true
+			if !s.Affected[e.caller] {
+				s.Affected[e.caller] = true
+				queue = append(queue, e.caller)
+			}
+		}
+	}
+
+	logf("%d changed functions affect %d functions", len(s.Changed), len(s.Affected))
+
+	return s
+}
+
+const (
+	entryMain      = "main"
+	entryHandler   = "handler"
+	entryTest      = "test"
+	entryBenchmark = "benchmark"
+	entryFuzz      = "fuzz"
+	entryExample   = "example"
+)
+
+var (
+	entryOrder  = []string{entryMain, entryHandler, entryTest, entryBenchmark, entryFuzz, entryExample}
+	httpHandler = regexp.MustCompile(`^func\((\w+ )?net/http\.ResponseWriter, (\w+ )?\*net/http\.Request\)$`)
+	testingArg  = regexp.MustCompile(`^func\((\w+ )?\*testing\.([TBF])\)$`)
+)
+
+// isTestName reports whether go test recognizes the name with the prefix,
+// i.e. the prefix is not followed by lower case letter, so Testify is not a test.
+func isTestName(name, prefix string) bool {
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return !unicode.IsLower(r)
+}
+
+// entryKind returns kind of entry point the function is,
+// empty string is returned for other functions.
+func entryKind(f *funcNode) string {
+	if f.Closure {
+		return ""
+	}
+	// main of generated test program is not an entry point
+	if f.Name == "main" && f.PkgName == "main" && !strings.HasSuffix(f.Pkg, ".test") {
+		return entryMain
+	}
+	if httpHandler.MatchString(f.Signature) {
+		return entryHandler
+	}
+	if f.Recv != "" || !strings.HasSuffix(f.Pos.Filename, "_test.go") {
+		return ""
+	}
+	// TestMain takes *testing.M, so it is not matched
+	var arg string
+	if m := testingArg.FindStringSubmatch(f.Signature); m != nil {
+		arg = m[2]
+	}
+	switch {
+	case arg == "T" && isTestName(f.Name, "Test"):
+		return entryTest
+	case arg == "B" && isTestName(f.Name, "Benchmark"):
+		return entryBenchmark
+	case arg == "F" && isTestName(f.Name, "Fuzz"):
+		return entryFuzz
+	case f.Signature == "func()" && isTestName(f.Name, "Example"):
+		return entryExample
+	}
+	return ""
+}
+
+type impactEntry struct {
+	Kind string
+	Func *funcNode
+}
+
+// impactTests is a go test command running affected tests of the package.
+type impactTests struct {
+	Package string
+	Run     string // regexp of tests, fuzz tests and examples
+	Bench   string // regexp of benchmarks
+}
+
+func (t *impactTests) Command() string {
+	run := t.Run
+	if run == "" {
+		run = "^$"
+	}
+	cmd := fmt.Sprintf("go test -run '%s'", run)
+	if t.Bench != "" {
+		cmd += fmt.Sprintf(" -bench '%s'", t.Bench)
+	}
+	return cmd + " " + t.Package
+}
+
+// Entries returns affected entry points sorted by their kind.
+func (s *impactSet) Entries() []impactEntry {
+	var entries []impactEntry
+	for _, f := range uniqueFuncs(s.Affected) {
+		if kind := entryKind(f); kind != "" {
+			entries = append(entries, impactEntry{kind, f})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Kind != b.Kind {
+			return slices.Index(entryOrder, a.Kind) < slices.Index(entryOrder, b.Kind)
+		}
+		return a.Func.ID < b.Func.ID
+	})
+	return entries
+}
+
+// Tests returns go test commands of affected tests grouped by package,
+// external test packages are run together with the tested package.
+func (s *impactSet) Tests() []*impactTests {
+	type names struct{ run, bench []string }
+	byPkg := make(map[string]*names)
+	for _, e := range s.Entries() {
+		if e.Kind == entryMain || e.Kind == entryHandler {
+			continue
+		}
+		pkg := strings.TrimSuffix(e.Func.Pkg, "_test")
+		if byPkg[pkg] == nil {
+			byPkg[pkg] = &names{}
+		}
+		if e.Kind == entryBenchmark {
+			byPkg[pkg].bench = append(byPkg[pkg].bench, e.Func.Name)
+		} else {
+			byPkg[pkg].run = append(byPkg[pkg].run, e.Func.Name)
+		}
+	}
+	pattern := func(names []string) string {
+		if len(names) == 0 {
+			return ""
+		}
+		sort.Strings(names)
+		return "^(" + strings.Join(names, "|") + ")$"
+	}
+	var tests []*impactTests
+	for pkg, n := range byPkg {
+		tests = append(tests, &impactTests{
+			Package: pkg,
+			Run:     pattern(n.run),
+			Bench:   pattern(n.bench),
+		})
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Package < tests[j].Package
+	})
+	return tests
+}
+
+// uniqueFuncs returns functions sorted by ID, functions of test variants
+// of packages have the same ID as in the package, so they are listed once.
+func uniqueFuncs(set map[*funcNode]bool) []*funcNode {
+	var funcs []*funcNode
+	for f := range set {
+		funcs = append(funcs, f)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].ID < funcs[j].ID
+	})
+	return slices.CompactFunc(funcs, func(a, b *funcNode) bool {
+		return a.ID == b.ID
+	})
+}
+
+// WriteText writes changed functions, affected entry points
+// and commands running affected tests.
+func (s *impactSet) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	changed := uniqueFuncs(s.Changed)
+	fmt.Fprintf(tw, "changed functions (%d):\n", len(changed))
+	for _, f := range changed {
+		fmt.Fprintf(tw, "  %s\t%s:%d\n", f.ShortName(), filepath.Base(f.Pos.Filename), f.Pos.Line)
+	}
+	entries := s.Entries()
+	fmt.Fprintf(tw, "\naffected entry points (%d):\n", len(entries))
+	for _, e := range entries {
+		fmt.Fprintf(tw, "  %s\t%s\n", e.Kind, e.Func.ShortName())
+	}
+	if tests := s.Tests(); len(tests) > 0 {
+		fmt.Fprintln(tw)
+		for _, t := range tests {
+			fmt.Fprintln(tw, t.Command())
+		}
+	}
+	return tw.Flush()
+}
+
+type jsonImpact struct {
+	Changed []jsonImpactFunc  `json:"changed"`
+	Entries []jsonImpactFunc  `json:"entryPoints"`
+	Tests   []jsonImpactTests `json:"tests"`
+}
+
+type jsonImpactFunc struct {
+	ID       string       `json:"id"`
+	Kind     string       `json:"kind,omitempty"`
+	Package  string       `json:"package"`
+	Position jsonPosition `json:"position"`
+}
+
+type jsonImpactTests struct {
+	Package string `json:"package"`
+	Run     string `json:"run,omitempty"`
+	Bench   string `json:"bench,omitempty"`
+	Command string `json:"command"`
+}
+
+func (s *impactSet) WriteJSON(w io.Writer) error {
+	out := jsonImpact{
+		Changed: []jsonImpactFunc{},
+		Entries: []jsonImpactFunc{},
+		Tests:   []jsonImpactTests{},
+	}
+	for _, f := range uniqueFuncs(s.Changed) {
+		out.Changed = append(out.Changed, jsonImpactFunc{
+			ID:       f.ID,
+			Package:  f.Pkg,
+			Position: newJSONPosition(f.Pos),
+		})
+	}
+	for _, e := range s.Entries() {
+		out.Entries = append(out.Entries, jsonImpactFunc{
+			ID:       e.Func.ID,
+			Kind:     e.Kind,
+			Package:  e.Func.Pkg,
+			Position: newJSONPosition(e.Func.Pos),
+		})
+	}
+	for _, t := range s.Tests() {
+		out.Tests = append(out.Tests, jsonImpactTests{
+			Package: t.Package,
+			Run:     t.Run,
+			Bench:   t.Bench,
+			Command: t.Command(),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
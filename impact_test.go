@@ -0,0 +1,144 @@
+package main
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -3 +3 @@ func A() {
+-	old()
++	new()
+@@ -10,2 +10,4 @@ func B() {
++	x()
++	y()
+@@ -20,3 +23,0 @@ func C() {
+-	removed()
+diff --git a/dir/new.go b/dir/new.go
+new file mode 100644
+--- /dev/null
++++ b/dir/new.go
+@@ -0,0 +1,5 @@
++package dir
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package gone
+`
+	want := map[string][]lineRange{
+		"/top/a.go":       {{3, 3}, {10, 13}, {23, 24}},
+		"/top/dir/new.go": {{1, 5}},
+	}
+	if got := parseDiff("/top", diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiff() = %v, want %v", got, want)
+	}
+}
+
+func TestEntryKind(t *testing.T) {
+	tests := []struct {
+		name string
+		f    funcNode
+		want string
+	}{
+		{"main", funcNode{Name: "main", PkgName: "main", Pkg: "example.com/cmd", Signature: "func()"}, entryMain},
+		{"main of test program", funcNode{Name: "main", PkgName: "main", Pkg: "example.com/cmd.test", Signature: "func()"}, ""},
+		{"handler", funcNode{Name: "Serve", Signature: "func(w net/http.ResponseWriter, r *net/http.Request)"}, entryHandler},
+		{"test", funcNode{Name: "TestRun", Signature: "func(t *testing.T)"}, entryTest},
+		{"test only prefix", funcNode{Name: "Test", Signature: "func(t *testing.T)"}, entryTest},
+		{"test with underscore", funcNode{Name: "Test_run", Signature: "func(t *testing.T)"}, entryTest},
+		{"lower case after prefix", funcNode{Name: "Testify", Signature: "func(t *testing.T)"}, ""},
+		{"TestMain", funcNode{Name: "TestMain", Signature: "func(m *testing.M)"}, ""},
+		{"test helper", funcNode{Name: "TestData", Signature: "func() []byte"}, ""},
+		{"benchmark", funcNode{Name: "BenchmarkRun", Signature: "func(b *testing.B)"}, entryBenchmark},
+		{"benchmark lower case", funcNode{Name: "Benchmarkrun", Signature: "func(b *testing.B)"}, ""},
+		{"fuzz", funcNode{Name: "FuzzParse", Signature: "func(f *testing.F)"}, entryFuzz},
+		{"fuzz with test argument", funcNode{Name: "FuzzParse", Signature: "func(t *testing.T)"}, ""},
+		{"example", funcNode{Name: "ExampleRun", Signature: "func()"}, entryExample},
+		{"example of method", funcNode{Name: "ExampleT_Run", Signature: "func()"}, entryExample},
+		{"example lower case", funcNode{Name: "Examples", Signature: "func()"}, ""},
+		{"method", funcNode{Name: "TestRun", Recv: "*example.com/pkg.T", Signature: "func(t *testing.T)"}, ""},
+		{"closure", funcNode{Name: "TestRun$1", Closure: true, Signature: "func(t *testing.T)"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.f
+			if f.Pos.Filename == "" {
+				f.Pos = token.Position{Filename: "/src/pkg/run_test.go", Line: 1}
+			}
+			if got := entryKind(&f); got != tt.want {
+				t.Errorf("entryKind(%s) = %q, want %q", f.Name, got, tt.want)
+			}
+		})
+	}
+
+	// tests are recognized only in test files
+	f := funcNode{Name: "TestRun", Signature: "func(t *testing.T)", Pos: token.Position{Filename: "/src/pkg/run.go"}}
+	if got := entryKind(&f); got != "" {
+		t.Errorf("entryKind(%s) outside test file = %q, want empty", f.Name, got)
+	}
+}
+
+func TestImpactTestsCommand(t *testing.T) {
+	tests := []struct {
+		tests impactTests
+		want  string
+	}{
+		{
+			impactTests{Package: "example.com/pkg", Run: "^(TestA|TestB)$"},
+			"go test -run '^(TestA|TestB)$' example.com/pkg",
+		},
+		{
+			impactTests{Package: "example.com/pkg", Bench: "^(BenchmarkA)$"},
+			"go test -run '^$' -bench '^(BenchmarkA)$' example.com/pkg",
+		},
+		{
+			impactTests{Package: "example.com/pkg", Run: "^(ExampleA|TestA)$", Bench: "^(BenchmarkA)$"},
+			"go test -run '^(ExampleA|TestA)$' -bench '^(BenchmarkA)$' example.com/pkg",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.tests.Command(); got != tt.want {
+			t.Errorf("Command() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestImpactTests(t *testing.T) {
+	g := testCallGraph(
+		"a.Changed -> a.Changed",
+		"a_test.TestChanged -> a.Changed",
+		"a_test.BenchmarkChanged -> a.Changed",
+		"a_test.TestMain -> a.Changed",
+		"a.Unchanged -> a.Unchanged",
+	)
+	signatures := map[string]string{
+		"TestChanged":      "func(t *testing.T)",
+		"BenchmarkChanged": "func(b *testing.B)",
+		"TestMain":         "func(m *testing.M)",
+	}
+	changes := &codeChanges{files: map[string][]lineRange{"/src/a/a.go": {{1, 1}}}}
+	for _, f := range g.Funcs {
+		f.Pos = token.Position{Filename: "/src/a/a_test.go", Line: 1}
+		f.Signature = signatures[f.Name]
+		if f.Name == "Changed" {
+			f.Pos.Filename = "/src/a/a.go"
+		}
+	}
+
+	got := changes.impact(g, &edgeFilter{}).Tests()
+	want := []*impactTests{{
+		Package: "example.com/a",
+		Run:     "^(TestChanged)$",
+		Bench:   "^(BenchmarkChanged)$",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tests() = %+v, want %+v", got, want)
+	}
+}
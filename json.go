@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"sort"
+)
+
+// ==[ type def/func: jsonGraph  ]===============================================
+type jsonGraph struct {
+	Title   string       `json:"title"`
+	Errors  []string     `json:"errors,omitempty"`
+	Nodes   []jsonNode   `json:"nodes"`
+	Edges   []jsonEdge   `json:"edges"`
+	Cluster *jsonCluster `json:"cluster"`
+	Layout  *jsonLayout  `json:"layout,omitempty"`
+}
+
+type jsonNode struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Package  string       `json:"package"`
+	Receiver string       `json:"receiver,omitempty"`
+	Exported bool         `json:"exported"`
+	Dead     bool         `json:"unreachable,omitempty"`
+	Position jsonPosition `json:"position"`
+	Cluster  string       `json:"cluster"`
+
+	// appearance in the graph, used by the viewer
+	Label   string `json:"label,omitempty"`
+	Focused bool   `json:"focused,omitempty"`
+	Color   string `json:"color,omitempty"`
+}
+
+type jsonEdge struct {
+	Caller string         `json:"caller"`
+	Callee string         `json:"callee"`
+	Kind   callKind       `json:"kind"`
+	Sites  []jsonPosition `json:"sites"`
+}
+
+type jsonCluster struct {
+	ID       string         `json:"id"`
+	Kind     string         `json:"kind,omitempty"`
+	Label    string         `json:"label"`
+	Nodes    []string       `json:"nodes,omitempty"`
+	Clusters []*jsonCluster `json:"clusters,omitempty"`
+}
+
+// jsonLayout is the built-in layout of the graph, so the viewer draws
+// the same layout as SVG rendered by the server.
+type jsonLayout struct {
+	Width    float64             `json:"width"`
+	Height   float64             `json:"height"`
+	Items    []jsonLayoutItem    `json:"items"`
+	Clusters []jsonLayoutCluster `json:"clusters"`
+	Edges    []jsonLayoutEdge    `json:"edges"`
+}
+
+// jsonLayoutItem is a laid out node, or a collapsed cluster
+// with ID prefixed by "cluster:".
+type jsonLayoutItem struct {
+	ID        string   `json:"id"`
+	Collapsed string   `json:"collapsed,omitempty"`
+	X         float64  `json:"x"`
+	Y         float64  `json:"y"`
+	W         float64  `json:"w"`
+	H         float64  `json:"h"`
+	Label     []string `json:"label"`
+	FontSize  float64  `json:"fontSize"`
+}
+
+type jsonLayoutCluster struct {
+	ID string  `json:"id"`
+	X0 float64 `json:"x0"`
+	Y0 float64 `json:"y0"`
+	X1 float64 `json:"x1"`
+	Y1 float64 `json:"y1"`
+}
+
+// jsonLayoutEdge connects items, calls are indexes of edges
+// drawn by it, several calls are merged for collapsed clusters.
+type jsonLayoutEdge struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Calls  []int        `json:"calls"`
+	Points [][2]float64 `json:"points"`
+}
+
+type jsonPosition struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func newJSONPosition(pos token.Position) jsonPosition {
+	return jsonPosition{
+		File:   pos.Filename,
+		Line:   pos.Line,
+		Column: pos.Column,
+	}
+}
+
+func (p jsonPosition) tokenPosition() token.Position {
+	return token.Position{
+		Filename: p.File,
+		Line:     p.Line,
+		Column:   p.Column,
+	}
+}
+
+func (g *dotGraph) WriteJSON(w io.Writer) error {
+	return g.writeJSON(w, nil)
+}
+
+// jsonWithLayout returns writer of JSON output including the built-in
+// layout with given clusters collapsed, which is drawn by the viewer.
+func jsonWithLayout(collapsed []string) func(*dotGraph, io.Writer) error {
+	return func(g *dotGraph, w io.Writer) error {
+		return g.writeJSON(w, newGraphLayout(g, collapsed...))
+	}
+}
+
+func (g *dotGraph) writeJSON(w io.Writer, l *graphLayout) error {
+	out := jsonGraph{
+		Title:  g.Title,
+		Errors: g.Errors,
+		Nodes:  []jsonNode{},
+		Edges:  []jsonEdge{},
+	}
+
+	addNode := func(n *dotNode, cluster string) {
+		out.Nodes = append(out.Nodes, jsonNode{
+			ID:       n.ID,
+			Name:     n.Name,
+			Package:  n.Pkg,
+			Receiver: n.Recv,
+			Exported: n.Exported,
+			Dead:     n.Dead,
+			Position: newJSONPosition(n.Pos),
+			Cluster:  cluster,
+			Label:    n.Attrs["label"],
+			Focused:  n.Focused,
+			Color:    n.Attrs["fillcolor"],
+		})
+	}
+
+	var walk func(c *dotCluster) *jsonCluster
+	walk = func(c *dotCluster) *jsonCluster {
+		jc := &jsonCluster{
+			ID:    c.ID,
+			Kind:  c.Kind,
+			Label: c.Attrs["label"],
+		}
+		for _, n := range c.Nodes {
+			jc.Nodes = append(jc.Nodes, n.ID)
+			addNode(n, c.ID)
+		}
+		sort.Strings(jc.Nodes)
+		for _, sub := range c.sortedClusters() {
+			jc.Clusters = append(jc.Clusters, walk(sub))
+		}
+		return jc
+	}
+	if g.Cluster != nil {
+		out.Cluster = walk(g.Cluster)
+	}
+	for _, n := range g.Nodes {
+		addNode(n, "")
+	}
+
+	edges := append([]*dotEdge(nil), g.Edges...)
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.From.ID != b.From.ID {
+			return a.From.ID < b.From.ID
+		}
+		if a.To.ID != b.To.ID {
+			return a.To.ID < b.To.ID
+		}
+		return a.Kind < b.Kind
+	})
+	for _, e := range edges {
+		je := jsonEdge{
+			Caller: e.From.ID,
+			Callee: e.To.ID,
+			Kind:   e.Kind,
+		}
+		for _, pos := range e.Sites {
+			je.Sites = append(je.Sites, newJSONPosition(pos))
+		}
+		out.Edges = append(out.Edges, je)
+	}
+
+	sort.Slice(out.Nodes, func(i, j int) bool {
+		return out.Nodes[i].ID < out.Nodes[j].ID
+	})
+
+	if l != nil {
+		out.Layout = newJSONLayout(l, edges)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// newJSONLayout converts the layout, edges are sorted as in the output.
+func newJSONLayout(l *graphLayout, edges []*dotEdge) *jsonLayout {
+	out := &jsonLayout{
+		Width:    l.Width,
+		Height:   l.Height,
+		Items:    []jsonLayoutItem{},
+		Clusters: []jsonLayoutCluster{},
+		Edges:    []jsonLayoutEdge{},
+	}
+
+	itemID := func(n *layoutNode) string {
+		if n.collapsed != nil {
+			return "cluster:" + n.collapsed.ID
+		}
+		return n.node.ID
+	}
+	for _, n := range l.nodes {
+		if n.node == nil && n.collapsed == nil {
+			continue
+		}
+		item := jsonLayoutItem{
+			ID:       itemID(n),
+			X:        n.X,
+			Y:        n.Y,
+			W:        n.w,
+			H:        n.h,
+			Label:    n.label,
+			FontSize: n.fontSize,
+		}
+		if n.collapsed != nil {
+			item.Collapsed = n.collapsed.ID
+		}
+		out.Items = append(out.Items, item)
+	}
+
+	var walk func(c *layoutCluster)
+	walk = func(c *layoutCluster) {
+		if c.cluster != nil && c.X0 <= c.X1 {
+			out.Clusters = append(out.Clusters, jsonLayoutCluster{
+				ID: c.cluster.ID,
+				X0: c.X0,
+				Y0: c.Y0,
+				X1: c.X1,
+				Y1: c.Y1,
+			})
+		}
+		for _, sub := range c.children {
+			walk(sub)
+		}
+	}
+	walk(l.root)
+
+	index := make(map[*dotEdge]int)
+	for i, e := range edges {
+		index[e] = i
+	}
+	for _, e := range l.edges {
+		je := jsonLayoutEdge{
+			From:   itemID(l.byNode[e.edge.From]),
+			To:     itemID(l.byNode[e.edge.To]),
+			Points: e.Points,
+		}
+		for _, call := range e.calls {
+			je.Calls = append(je.Calls, index[call])
+		}
+		out.Edges = append(out.Edges, je)
+	}
+	return out
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	g := testDotGraph()
+	g.Edges[0].Sites = []token.Position{{Filename: "main.go", Line: 7, Column: 5}}
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out jsonGraph
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	if out.Title != "example.com/cmd" {
+		t.Errorf("title = %q, want %q", out.Title, "example.com/cmd")
+	}
+
+	nodes := make(map[string]jsonNode)
+	var ids []string
+	for _, n := range out.Nodes {
+		nodes[n.ID] = n
+		ids = append(ids, n.ID)
+	}
+	wantIDs := []string{"(*example.com/pkg.T).Do", "example.com/cmd.main", "example.com/pkg.Run"}
+	if len(ids) != len(wantIDs) {
+		t.Fatalf("nodes = %q, want %q", ids, wantIDs)
+	}
+	for i := range wantIDs {
+		if ids[i] != wantIDs[i] {
+			t.Errorf("nodes[%d] = %q, want %q", i, ids[i], wantIDs[i])
+		}
+	}
+
+	for _, tt := range []struct {
+		id       string
+		cluster  string
+		receiver string
+		label    string
+		focused  bool
+		exported bool
+	}{
+		{"example.com/cmd.main", "focus", "", "main", true, false},
+		{"example.com/pkg.Run", "example.com/pkg", "", "pkg\nRun", false, true},
+		{"(*example.com/pkg.T).Do", "*example.com/pkg.T", "*example.com/pkg.T", "Do", false, true},
+	} {
+		n := nodes[tt.id]
+		if n.Cluster != tt.cluster || n.Receiver != tt.receiver || n.Label != tt.label ||
+			n.Focused != tt.focused || n.Exported != tt.exported {
+			t.Errorf("node %s = %+v, want cluster %q, receiver %q, label %q, focused %v, exported %v",
+				tt.id, n, tt.cluster, tt.receiver, tt.label, tt.focused, tt.exported)
+		}
+	}
+
+	wantEdges := []struct {
+		caller, callee string
+		kind           callKind
+		sites          int
+	}{
+		{"example.com/cmd.main", "(*example.com/pkg.T).Do", callKindGo, 0},
+		{"example.com/cmd.main", "example.com/pkg.Run", callKindStatic, 1},
+		{"example.com/pkg.Run", "(*example.com/pkg.T).Do", callKindDynamic, 0},
+	}
+	if len(out.Edges) != len(wantEdges) {
+		t.Fatalf("got %d edges, want %d", len(out.Edges), len(wantEdges))
+	}
+	for i, want := range wantEdges {
+		e := out.Edges[i]
+		if e.Caller != want.caller || e.Callee != want.callee || e.Kind != want.kind || len(e.Sites) != want.sites {
+			t.Errorf("edges[%d] = %+v, want %+v", i, e, want)
+		}
+	}
+	if site := out.Edges[1].Sites[0]; site != (jsonPosition{File: "main.go", Line: 7, Column: 5}) {
+		t.Errorf("site = %+v, want main.go:7:5", site)
+	}
+
+	var clusters []string
+	var walk func(c *jsonCluster)
+	walk = func(c *jsonCluster) {
+		clusters = append(clusters, c.ID+" "+c.Kind+" "+c.Label)
+		for _, sub := range c.Clusters {
+			walk(sub)
+		}
+	}
+	if out.Cluster == nil {
+		t.Fatal("cluster is missing")
+	}
+	walk(out.Cluster)
+	wantClusters := []string{
+		"focus  main",
+		"example.com/pkg package pkg",
+		"*example.com/pkg.T type (*T)",
+	}
+	if len(clusters) != len(wantClusters) {
+		t.Fatalf("clusters = %q, want %q", clusters, wantClusters)
+	}
+	for i := range wantClusters {
+		if clusters[i] != wantClusters[i] {
+			t.Errorf("clusters[%d] = %q, want %q", i, clusters[i], wantClusters[i])
+		}
+	}
+}
+
+func TestLoadJSONGraph(t *testing.T) {
+	g := testDotGraph()
+	g.Edges[0].Sites = []token.Position{
+		{Filename: "main.go", Line: 7, Column: 5},
+		{Filename: "main.go", Line: 9, Column: 2},
+	}
+	g.Edges[1].Sites = []token.Position{{Filename: "pkg.go", Line: 12, Column: 3}}
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "graph.json")
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cg, err := loadJSONGraph(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cg.Funcs) != 3 {
+		t.Fatalf("got %d functions, want 3", len(cg.Funcs))
+	}
+	if len(cg.Packages) != 2 || cg.Packages[0].Path != "example.com/cmd" || cg.Packages[1].Path != "example.com/pkg" {
+		t.Errorf("packages = %+v, want example.com/cmd and example.com/pkg", cg.Packages)
+	}
+
+	// edges without sites are not calls that can be loaded back
+	var got []string
+	for _, e := range cg.Edges {
+		got = append(got, cg.Funcs[e.Caller].ID+" -> "+cg.Funcs[e.Callee].ID+" "+string(e.Kind)+" "+e.Pos.String())
+	}
+	want := []string{
+		"example.com/cmd.main -> example.com/pkg.Run static main.go:7:5",
+		"example.com/cmd.main -> example.com/pkg.Run static main.go:9:2",
+		"example.com/pkg.Run -> (*example.com/pkg.T).Do dynamic pkg.go:12:3",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("calls = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("calls[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWriteJSONLayout(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jsonWithLayout([]string{"example.com/pkg"})(testDotGraph(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	var out jsonGraph
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	l := out.Layout
+	if l == nil {
+		t.Fatal("layout is missing")
+	}
+
+	var items []string
+	for _, item := range l.Items {
+		items = append(items, item.ID+" "+item.Collapsed)
+	}
+	wantItems := []string{"example.com/cmd.main ", "cluster:example.com/pkg example.com/pkg"}
+	if strings.Join(items, ",") != strings.Join(wantItems, ",") {
+		t.Errorf("items = %q, want %q", items, wantItems)
+	}
+	if len(l.Clusters) != 1 || l.Clusters[0].ID != "focus" {
+		t.Errorf("clusters = %+v, want only focus", l.Clusters)
+	}
+
+	// calls are indexes into edges of the graph
+	if len(l.Edges) != 1 {
+		t.Fatalf("got %d layout edges, want 1", len(l.Edges))
+	}
+	e := l.Edges[0]
+	if e.From != "example.com/cmd.main" || e.To != "cluster:example.com/pkg" || len(e.Points) < 2 {
+		t.Errorf("layout edge = %+v", e)
+	}
+	var calls []string
+	for _, i := range e.Calls {
+		calls = append(calls, out.Edges[i].Caller+" -> "+out.Edges[i].Callee)
+	}
+	wantCalls := []string{
+		"example.com/cmd.main -> (*example.com/pkg.T).Do",
+		"example.com/cmd.main -> example.com/pkg.Run",
+	}
+	if strings.Join(calls, ",") != strings.Join(wantCalls, ",") {
+		t.Errorf("calls of layout edge = %q, want %q", calls, wantCalls)
+	}
+
+	// layout is included only when requested
+	buf.Reset()
+	if err := testDotGraph().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `"layout"`) {
+		t.Error("JSON output contains layout")
+	}
+}
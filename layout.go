@@ -0,0 +1,557 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sizes of the built-in layout in points
+const (
+	layoutMargin     = 16.0
+	layoutClusterPad = 8.0
+	layoutDummySize  = 4.0
+	layoutIterations = 8
+)
+
+// ==[ type def/func: graphLayout ]==============================================
+
+// graphLayout is a layered (Sugiyama-style) layout of the graph used for
+// rendering SVG without Graphviz. Cycles are broken by reversing calls,
+// functions are ranked by the longest path from callers and calls spanning
+// several ranks are routed through dummy nodes. Clusters are placed in bands
+// ordered by barycenters of their nodes, so they never overlap.
+type graphLayout struct {
+	graph     *dotGraph
+	root      *layoutCluster
+	nodes     []*layoutNode // including dummy nodes
+	edges     []*layoutEdge
+	byNode    map[*dotNode]*layoutNode
+	collapsed map[string]bool // IDs of clusters laid out as single nodes
+	vertical  bool            // ranks go from top to bottom
+	reversed  bool            // ranks go from right to left or bottom to top
+	ranks     int
+	nodeSep   float64
+
+	Width, Height float64
+}
+
+type layoutNode struct {
+	node      *dotNode    // nil for dummy nodes and collapsed clusters
+	collapsed *dotCluster // cluster laid out as this node
+	cluster   *layoutCluster
+	label     []string
+	fontSize  float64
+	w, h      float64
+
+	rank      int
+	pos       float64 // center in order direction
+	bary      float64 // barycenter of neighbors
+	neighbors []*layoutNode
+
+	X, Y float64 // center
+}
+
+type layoutCluster struct {
+	cluster  *dotCluster // nil for the graph itself
+	parent   *layoutCluster
+	children []*layoutCluster
+	nodes    []*layoutNode
+	depth    int
+
+	label     string
+	labelSize float64
+	bary      float64
+
+	start, end float64 // band in order direction
+
+	X0, Y0, X1, Y1 float64 // bounding box, empty cluster has X0 > X1
+}
+
+type layoutEdge struct {
+	edge  *dotEdge
+	calls []*dotEdge    // all calls drawn, merged for collapsed clusters
+	path  []*layoutNode // from caller to callee, including dummy nodes
+
+	Points [][2]float64
+}
+
+// attrFloat returns value of numeric attribute or default.
+func attrFloat(attrs dotAttrs, key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(attrs[key], 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// textSize estimates size of text lines, there are no font metrics
+// available, so average width of Verdana characters is used.
+func textSize(lines []string, fontSize float64) (w, h float64) {
+	for _, line := range lines {
+		w = math.Max(w, float64(len([]rune(line)))*fontSize*0.62)
+	}
+	return w, float64(len(lines)) * fontSize * 1.2
+}
+
+// newGraphLayout lays out the graph, clusters with given IDs are
+// collapsed into single nodes and calls inside them are omitted.
+func newGraphLayout(g *dotGraph, collapsed ...string) *graphLayout {
+	l := &graphLayout{
+		graph:     g,
+		root:      &layoutCluster{},
+		byNode:    make(map[*dotNode]*layoutNode),
+		collapsed: make(map[string]bool),
+		nodeSep:   72 * attrFloat(dotAttrs(g.Options), "nodesep", 0.25),
+	}
+	for _, id := range collapsed {
+		l.collapsed[id] = true
+	}
+	switch g.Options["rankdir"] {
+	case "TB":
+		l.vertical = true
+	case "BT":
+		l.vertical, l.reversed = true, true
+	case "RL":
+		l.reversed = true
+	}
+
+	for _, n := range (&dotCluster{Nodes: g.Nodes}).sortedNodes() {
+		l.addNode(n, l.root)
+	}
+	if g.Cluster != nil {
+		l.addCluster(g.Cluster, l.root)
+	}
+	l.rank()
+	for i := 0; i < layoutIterations; i++ {
+		for _, n := range l.nodes {
+			n.bary = n.pos
+			if len(n.neighbors) > 0 {
+				n.bary = 0
+				for _, m := range n.neighbors {
+					n.bary += m.pos
+				}
+				n.bary /= float64(len(n.neighbors))
+			}
+		}
+		l.root.barycenter()
+		l.arrange(l.root, 0)
+	}
+	l.place()
+
+	logf("layout of %d nodes in %d ranks: %.0fx%.0f", len(l.nodes), l.ranks, l.Width, l.Height)
+
+	return l
+}
+
+func (l *graphLayout) addCluster(c *dotCluster, parent *layoutCluster) {
+	lc := &layoutCluster{
+		cluster: c,
+		parent:  parent,
+		depth:   parent.depth + 1,
+		label:   c.Attrs["label"],
+	}
+	if lc.label != "" {
+		lc.labelSize = attrFloat(c.Attrs, "fontsize", 14) * 1.5
+	}
+	parent.children = append(parent.children, lc)
+	for _, n := range c.sortedNodes() {
+		l.addNode(n, lc)
+	}
+	for _, sub := range c.sortedClusters() {
+		if l.collapsed[sub.ID] {
+			l.addCollapsed(sub, lc)
+			continue
+		}
+		l.addCluster(sub, lc)
+	}
+}
+
+func (l *graphLayout) addNode(n *dotNode, c *layoutCluster) {
+	label, ok := n.Attrs["label"]
+	if !ok {
+		label = n.ID
+	}
+	ln := l.newNode(strings.Split(label, "\n"), attrFloat(n.Attrs, "fontsize", 14), c)
+	ln.node = n
+	l.byNode[n] = ln
+}
+
+// addCollapsed adds node standing for the cluster and all its functions,
+// labeled by the cluster with number of the functions.
+func (l *graphLayout) addCollapsed(c *dotCluster, parent *layoutCluster) {
+	var nodes []*dotNode
+	var walk func(c *dotCluster)
+	walk = func(c *dotCluster) {
+		nodes = append(nodes, c.Nodes...)
+		for _, sub := range c.Clusters {
+			walk(sub)
+		}
+	}
+	walk(c)
+
+	label := c.Attrs["label"]
+	if label == "" {
+		label = c.ID
+	}
+	ln := l.newNode([]string{fmt.Sprintf("%s (%d)", label, len(nodes))}, 14, parent)
+	ln.collapsed = c
+	for _, n := range nodes {
+		l.byNode[n] = ln
+	}
+}
+
+// newNode adds node sized to fit its label to the cluster.
+func (l *graphLayout) newNode(label []string, fontSize float64, c *layoutCluster) *layoutNode {
+	ln := &layoutNode{
+		cluster:  c,
+		label:    label,
+		fontSize: fontSize,
+		pos:      float64(len(l.nodes)),
+	}
+	w, h := textSize(ln.label, ln.fontSize)
+	ln.w = math.Max(w+2*11.5, 54)
+	ln.h = math.Max(h+8, 36)
+	c.nodes = append(c.nodes, ln)
+	l.nodes = append(l.nodes, ln)
+	return ln
+}
+
+// extent returns size of the node in rank and order direction.
+func (l *graphLayout) extent(n *layoutNode) (rank, order float64) {
+	if l.vertical {
+		return n.h, n.w
+	}
+	return n.w, n.h
+}
+
+// commonCluster returns the innermost cluster containing both clusters.
+func commonCluster(a, b *layoutCluster) *layoutCluster {
+	for a.depth > b.depth {
+		a = a.parent
+	}
+	for b.depth > a.depth {
+		b = b.parent
+	}
+	for a != b {
+		a, b = a.parent, b.parent
+	}
+	return a
+}
+
+// rank assigns ranks to nodes and routes calls through dummy nodes.
+func (l *graphLayout) rank() {
+	type arc struct {
+		from, to *layoutNode
+		edge     *layoutEdge
+		reversed bool
+	}
+	type pair struct{ from, to *layoutNode }
+	var arcs []*arc
+	out := make(map[*layoutNode][]*arc)
+	merged := make(map[pair]*layoutEdge)
+	for _, e := range l.graph.sortedEdges() {
+		from, to := l.byNode[e.From], l.byNode[e.To]
+		if from == nil || to == nil {
+			continue
+		}
+		// calls inside collapsed clusters are omitted,
+		// calls between the same nodes are drawn once
+		if from.collapsed != nil || to.collapsed != nil {
+			if from == to {
+				continue
+			}
+			if le := merged[pair{from, to}]; le != nil {
+				le.calls = append(le.calls, e)
+				continue
+			}
+		}
+		le := &layoutEdge{edge: e, calls: []*dotEdge{e}}
+		merged[pair{from, to}] = le
+		l.edges = append(l.edges, le)
+		if from == to {
+			le.path = []*layoutNode{from}
+			continue
+		}
+		a := &arc{from: from, to: to, edge: le}
+		arcs = append(arcs, a)
+		out[from] = append(out[from], a)
+	}
+
+	// break cycles by reversing calls leading back in depth-first search
+	state := make(map[*layoutNode]int)
+	var visit func(n *layoutNode)
+	visit = func(n *layoutNode) {
+		state[n] = 1
+		for _, a := range out[n] {
+			switch state[a.to] {
+			case 0:
+				visit(a.to)
+			case 1:
+				a.reversed = true
+			}
+		}
+		state[n] = 2
+	}
+	for _, n := range l.nodes {
+		if state[n] == 0 {
+			visit(n)
+		}
+	}
+
+	// longest path ranking in topological order
+	indeg := make(map[*layoutNode]int)
+	next := make(map[*layoutNode][]*layoutNode)
+	for _, a := range arcs {
+		from, to := a.from, a.to
+		if a.reversed {
+			from, to = to, from
+		}
+		indeg[to]++
+		next[from] = append(next[from], to)
+	}
+	var queue []*layoutNode
+	for _, n := range l.nodes {
+		if indeg[n] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		l.ranks = max(l.ranks, n.rank+1)
+		for _, m := range next[n] {
+			m.rank = max(m.rank, n.rank+1)
+			if indeg[m]--; indeg[m] == 0 {
+				queue = append(queue, m)
+			}
+		}
+	}
+
+	for _, a := range arcs {
+		from, to := a.from, a.to
+		if a.reversed {
+			from, to = to, from
+		}
+		path := []*layoutNode{from}
+		c := commonCluster(from.cluster, to.cluster)
+		for r := from.rank + 1; r < to.rank; r++ {
+			d := &layoutNode{
+				cluster: c,
+				rank:    r,
+				pos:     from.pos,
+				w:       layoutDummySize,
+				h:       layoutDummySize,
+			}
+			c.nodes = append(c.nodes, d)
+			l.nodes = append(l.nodes, d)
+			path = append(path, d)
+		}
+		path = append(path, to)
+		for i := 1; i < len(path); i++ {
+			path[i-1].neighbors = append(path[i-1].neighbors, path[i])
+			path[i].neighbors = append(path[i].neighbors, path[i-1])
+		}
+		if a.reversed {
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+		}
+		a.edge.path = path
+	}
+}
+
+// barycenter sets barycenters of clusters to average of their nodes.
+func (c *layoutCluster) barycenter() (sum float64, count int) {
+	for _, n := range c.nodes {
+		sum += n.bary
+		count++
+	}
+	for _, sub := range c.children {
+		s, n := sub.barycenter()
+		sum, count = sum+s, count+n
+	}
+	if count > 0 {
+		c.bary = sum / float64(count)
+	}
+	return sum, count
+}
+
+// arrange places nodes of the cluster in order direction from start and
+// returns size of the cluster. Nodes of the cluster form a band sorted
+// together with bands of sub-clusters by their barycenters.
+func (l *graphLayout) arrange(c *layoutCluster, start float64) float64 {
+	pos := start
+	if c.cluster != nil {
+		pos += layoutClusterPad
+		if !l.vertical {
+			pos += c.labelSize
+		}
+	}
+
+	type band struct {
+		bary    float64
+		cluster *layoutCluster // nil for nodes of c
+	}
+	var bands []band
+	if len(c.nodes) > 0 {
+		bary := 0.0
+		for _, n := range c.nodes {
+			bary += n.bary
+		}
+		bands = append(bands, band{bary / float64(len(c.nodes)), nil})
+	}
+	for _, sub := range c.children {
+		bands = append(bands, band{sub.bary, sub})
+	}
+	sort.SliceStable(bands, func(i, j int) bool {
+		return bands[i].bary < bands[j].bary
+	})
+
+	for i, b := range bands {
+		if i > 0 {
+			pos += l.nodeSep
+		}
+		if b.cluster != nil {
+			pos += l.arrange(b.cluster, pos)
+			continue
+		}
+		byRank := make(map[int][]*layoutNode)
+		for _, n := range c.nodes {
+			byRank[n.rank] = append(byRank[n.rank], n)
+		}
+		size := 0.0
+		for _, nodes := range byRank {
+			sort.SliceStable(nodes, func(i, j int) bool {
+				return nodes[i].bary < nodes[j].bary
+			})
+			p := pos
+			for k, n := range nodes {
+				if k > 0 {
+					p += l.nodeSep
+				}
+				_, ext := l.extent(n)
+				n.pos = p + ext/2
+				p += ext
+			}
+			size = math.Max(size, p-pos)
+		}
+		pos += size
+	}
+
+	if c.cluster != nil {
+		pos += layoutClusterPad
+	}
+	c.start, c.end = start, pos
+	return pos - start
+}
+
+// place computes coordinates of nodes, clusters and calls.
+func (l *graphLayout) place() {
+	depth := 0
+	rankSize := make([]float64, l.ranks)
+	for _, n := range l.nodes {
+		r, _ := l.extent(n)
+		rankSize[n.rank] = math.Max(rankSize[n.rank], r)
+		depth = max(depth, n.cluster.depth)
+	}
+	rankSep := float64(max(l.graph.Minlen, 1))*18 + 2*layoutClusterPad*float64(depth)
+	rankPos := make([]float64, l.ranks)
+	total := 0.0
+	for r := range rankSize {
+		if r > 0 {
+			total += rankSep
+		}
+		rankPos[r] = total + rankSize[r]/2
+		total += rankSize[r]
+	}
+
+	offset := layoutMargin + 2*layoutClusterPad*float64(depth) + l.root.labelSize
+	for _, n := range l.nodes {
+		r := rankPos[n.rank]
+		if l.reversed {
+			r = total - r
+		}
+		r += offset
+		o := n.pos + layoutMargin
+		if l.vertical {
+			n.X, n.Y = o, r
+		} else {
+			n.X, n.Y = r, o
+		}
+		l.Width = math.Max(l.Width, n.X+n.w/2+layoutMargin)
+		l.Height = math.Max(l.Height, n.Y+n.h/2+layoutMargin)
+	}
+
+	var bound func(c *layoutCluster)
+	bound = func(c *layoutCluster) {
+		c.X0, c.Y0, c.X1, c.Y1 = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, n := range c.nodes {
+			c.X0, c.Y0 = math.Min(c.X0, n.X-n.w/2), math.Min(c.Y0, n.Y-n.h/2)
+			c.X1, c.Y1 = math.Max(c.X1, n.X+n.w/2), math.Max(c.Y1, n.Y+n.h/2)
+		}
+		for _, sub := range c.children {
+			bound(sub)
+			if sub.X0 <= sub.X1 {
+				c.X0, c.Y0 = math.Min(c.X0, sub.X0), math.Min(c.Y0, sub.Y0)
+				c.X1, c.Y1 = math.Max(c.X1, sub.X1), math.Max(c.Y1, sub.Y1)
+			}
+		}
+		if c.X0 > c.X1 || c.cluster == nil {
+			return
+		}
+		// bands were padded already in order direction
+		if l.vertical {
+			c.X0, c.X1 = c.start+layoutMargin, c.end+layoutMargin
+			c.Y0 -= layoutClusterPad + c.labelSize
+			c.Y1 += layoutClusterPad
+		} else {
+			c.Y0, c.Y1 = c.start+layoutMargin, c.end+layoutMargin
+			c.X0 -= layoutClusterPad
+			c.X1 += layoutClusterPad
+		}
+		l.Width = math.Max(l.Width, c.X1+layoutMargin)
+		l.Height = math.Max(l.Height, c.Y1+layoutMargin)
+	}
+	bound(l.root)
+
+	for _, e := range l.edges {
+		path := e.path
+		if len(path) == 1 {
+			// self call is a loop above the node
+			n := path[0]
+			x, y := n.X, n.Y-n.h/2
+			e.Points = [][2]float64{
+				{x + n.w/6, y},
+				{x + n.w/6 + 10, y - 24},
+				{x - n.w/6 - 10, y - 24},
+				{x - n.w/6, y},
+			}
+			continue
+		}
+		first, last := path[0], path[len(path)-1]
+		e.Points = append(e.Points, clipToNode(first, path[1].X, path[1].Y))
+		for _, d := range path[1 : len(path)-1] {
+			e.Points = append(e.Points, [2]float64{d.X, d.Y})
+		}
+		prev := path[len(path)-2]
+		e.Points = append(e.Points, clipToNode(last, prev.X, prev.Y))
+	}
+}
+
+// clipToNode returns point on border of the node in direction to x, y.
+func clipToNode(n *layoutNode, x, y float64) [2]float64 {
+	dx, dy := x-n.X, y-n.Y
+	if dx == 0 && dy == 0 {
+		return [2]float64{n.X, n.Y}
+	}
+	s := math.Inf(1)
+	if dx != 0 {
+		s = math.Min(s, n.w/2/math.Abs(dx))
+	}
+	if dy != 0 {
+		s = math.Min(s, n.h/2/math.Abs(dy))
+	}
+	return [2]float64{n.X + dx*s, n.Y + dy*s}
+}
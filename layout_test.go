@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// overlaps tells whether boxes of two nodes intersect.
+func overlaps(a, b *layoutNode) bool {
+	return math.Abs(a.X-b.X) < (a.w+b.w)/2 && math.Abs(a.Y-b.Y) < (a.h+b.h)/2
+}
+
+func TestGraphLayout(t *testing.T) {
+	for _, tt := range []struct {
+		rankdir string
+		// forward tells whether callee is placed after caller
+		forward func(from, to *layoutNode) bool
+	}{
+		{"LR", func(from, to *layoutNode) bool { return from.X < to.X }},
+		{"RL", func(from, to *layoutNode) bool { return from.X > to.X }},
+		{"TB", func(from, to *layoutNode) bool { return from.Y < to.Y }},
+		{"BT", func(from, to *layoutNode) bool { return from.Y > to.Y }},
+	} {
+		t.Run(tt.rankdir, func(t *testing.T) {
+			g := testDotGraph()
+			g.Options["rankdir"] = tt.rankdir
+			l := newGraphLayout(g)
+
+			if l.ranks != 3 {
+				t.Errorf("ranks = %d, want 3", l.ranks)
+			}
+			var real []*layoutNode
+			for _, n := range l.nodes {
+				if n.X-n.w/2 < 0 || n.Y-n.h/2 < 0 || n.X+n.w/2 > l.Width || n.Y+n.h/2 > l.Height {
+					t.Errorf("node %v at %.0f,%.0f is outside of %.0fx%.0f", n.label, n.X, n.Y, l.Width, l.Height)
+				}
+				if n.node != nil {
+					real = append(real, n)
+				}
+			}
+			if len(real) != 3 {
+				t.Fatalf("got %d nodes, want 3", len(real))
+			}
+			for i, a := range l.nodes {
+				for _, b := range l.nodes[i+1:] {
+					if overlaps(a, b) {
+						t.Errorf("nodes %v and %v overlap", a.label, b.label)
+					}
+				}
+			}
+
+			// nodes are inside boxes of all clusters containing them
+			for _, n := range real {
+				for c := n.cluster; c.cluster != nil; c = c.parent {
+					if n.X-n.w/2 < c.X0 || n.X+n.w/2 > c.X1 || n.Y-n.h/2 < c.Y0 || n.Y+n.h/2 > c.Y1 {
+						t.Errorf("node %v is outside of cluster %s", n.label, c.cluster.ID)
+					}
+				}
+			}
+
+			if len(l.edges) != len(g.Edges) {
+				t.Fatalf("got %d edges, want %d", len(l.edges), len(g.Edges))
+			}
+			for _, e := range l.edges {
+				from, to := l.byNode[e.edge.From], l.byNode[e.edge.To]
+				if !tt.forward(from, to) {
+					t.Errorf("call %s -> %s goes backwards", e.edge.From.ID, e.edge.To.ID)
+				}
+				// one point per rank the call spans
+				if want := to.rank - from.rank + 1; len(e.Points) != want {
+					t.Errorf("call %s -> %s has %d points, want %d", e.edge.From.ID, e.edge.To.ID, len(e.Points), want)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphLayoutCycles(t *testing.T) {
+	a := &dotNode{ID: "a", Attrs: dotAttrs{}}
+	b := &dotNode{ID: "b", Attrs: dotAttrs{"label": "b\nwith two lines"}}
+	c := &dotNode{ID: "c", Attrs: dotAttrs{}}
+	g := &dotGraph{
+		Nodes:   []*dotNode{a, b, c},
+		Options: map[string]string{},
+		Edges: []*dotEdge{
+			{From: a, To: b, Attrs: dotAttrs{}},
+			{From: b, To: c, Attrs: dotAttrs{}},
+			{From: c, To: a, Attrs: dotAttrs{}},
+			{From: a, To: a, Attrs: dotAttrs{}},
+		},
+	}
+	l := newGraphLayout(g)
+
+	if l.ranks != 3 {
+		t.Errorf("ranks = %d, want 3", l.ranks)
+	}
+	ranks := make(map[int]bool)
+	for _, n := range l.nodes {
+		ranks[n.rank] = true
+	}
+	if len(ranks) != 3 {
+		t.Errorf("nodes of cycle use %d ranks, want 3", len(ranks))
+	}
+	if lb := l.byNode[b]; lb.h <= l.byNode[a].h {
+		t.Errorf("node with two lines is not taller: %.0f <= %.0f", lb.h, l.byNode[a].h)
+	}
+	for _, e := range l.edges {
+		if len(e.Points) < 2 {
+			t.Errorf("call %s -> %s has %d points", e.edge.From.ID, e.edge.To.ID, len(e.Points))
+			continue
+		}
+		// calls start and end on borders of nodes
+		from, to := l.byNode[e.edge.From], l.byNode[e.edge.To]
+		first, last := e.Points[0], e.Points[len(e.Points)-1]
+		if !onBorder(from, first) || !onBorder(to, last) {
+			t.Errorf("call %s -> %s from %v to %v does not touch nodes", e.edge.From.ID, e.edge.To.ID, first, last)
+		}
+	}
+}
+
+// onBorder tells whether point lies on border of the node.
+func onBorder(n *layoutNode, p [2]float64) bool {
+	const eps = 1e-6
+	dx, dy := math.Abs(p[0]-n.X), math.Abs(p[1]-n.Y)
+	if dx > n.w/2+eps || dy > n.h/2+eps {
+		return false
+	}
+	return math.Abs(dx-n.w/2) < eps || math.Abs(dy-n.h/2) < eps
+}
+
+func TestWriteSVG(t *testing.T) {
+	g := testDotGraph()
+	g.Errors = []string{"example.com/bad: <broken> & more"}
+	g.Mains = []dotMain{{Path: "example.com/cmd", URL: "/?main=example.com/cmd", Selected: true}}
+	g.DeadURL = "/?dead=1"
+
+	var buf bytes.Buffer
+	if err := g.WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var texts []string
+	dec := xml.NewDecoder(&buf)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v", err)
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			if s := strings.TrimSpace(string(cd)); s != "" {
+				texts = append(texts, s)
+			}
+		}
+	}
+	all := strings.Join(texts, "\n")
+	for _, want := range []string{
+		"example.com/cmd",
+		"main",
+		"Run",
+		"example.com/bad: <broken> & more",
+		"show unreachable functions",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("SVG does not contain text %q:\n%s", want, all)
+		}
+	}
+}
+
+func TestGraphLayoutCollapsed(t *testing.T) {
+	g := testDotGraph()
+	l := newGraphLayout(g, "example.com/pkg")
+
+	// functions of the package and its types share single node
+	pkg := l.byNode[g.Edges[0].To]
+	if pkg.collapsed == nil || pkg.collapsed.ID != "example.com/pkg" {
+		t.Fatalf("function of collapsed package is laid out as %+v", pkg)
+	}
+	if l.byNode[g.Edges[1].To] != pkg {
+		t.Error("method of type in collapsed package has own node")
+	}
+	if got := strings.Join(pkg.label, "\n"); got != "pkg (2)" {
+		t.Errorf("label of collapsed package = %q, want %q", got, "pkg (2)")
+	}
+	if len(l.root.children) != 1 || len(l.root.children[0].children) != 0 {
+		t.Error("collapsed package is laid out as cluster")
+	}
+
+	// calls into the package are merged, calls inside it are omitted
+	if len(l.edges) != 1 {
+		t.Fatalf("got %d edges, want 1", len(l.edges))
+	}
+	if e := l.edges[0]; len(e.calls) != 2 || l.byNode[e.edge.To] != pkg {
+		t.Errorf("edge into collapsed package has %d calls, want 2", len(e.calls))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/pkg/browser"
@@ -20,16 +21,67 @@ const Usage = `go-callvis: visualize call graph of a Go program.
 
 Usage:
 
-  go-callvis [flags] package
+  go-callvis [flags] package...
+  go-callvis deadcode [flags] package...
+  go-callvis stats [flags] package...
+  go-callvis cycles [flags] package...
+  go-callvis check -rules=<file> [flags] package...
+  go-callvis diff -base=<revision|file.json> [-head=<revision>] [flags] package...
+  go-callvis impact -gitDiff=<range> | -changed=<files> [flags] package...
 
-  Package should be main package, otherwise -tests flag must be used.
+  Packages should be main packages, otherwise -tests or -lib flag must be used.
+  Several packages or patterns (e.g. ./cmd/... ./internal/...) can be analyzed at once.
+
+  The deadcode command lists functions of the analyzed module unreachable
+  from main and init functions, as text or JSON with -format=json.
+  Reachability is always computed using RTA, other -algo values are rejected.
+
+  The stats command prints fan-in, fan-out, betweenness and reach of functions
+  and coupling of packages in the filtered call graph, as text or with
+  -format=csv or -format=json.
+
+  The cycles command lists recursive functions and groups of mutually
+  recursive functions with the calls forming them.
+
+  The check command lists calls denied by rules in the given file and exits
+  with non-zero code if there are any.
+
+  The diff command compares call graph of the base git revision, or JSON output
+  of previous run, with the head revision or the working tree. Summary of added
+  and removed functions and calls is printed as Markdown, merged graph is written
+  to the output file if -file is given.
+
+  The impact command lists functions changed by the git diff or in the changed
+  files, entry points calling them (main, HTTP handlers and tests with -tests)
+  and go test commands running affected tests, as text or JSON with -format=json.
+  Unless -algo is given, the call graph is constructed using CHA, so callers
+  through interfaces and function values are not missed.
 
 Flags:
 
 `
 
 var (
-	focusFlag     = flag.String("focus", "main", "Focus specific package using name or import path.")
+	focusFlag     = flag.String("focus", "main", "Focus specific package using name or import path, or function using its full name (e.g. '(*pkg.Type).Method').")
+	callerDepth   = flag.Int("callerDepth", 1, "Depth of callers shown for focused function.")
+	calleeDepth   = flag.Int("calleeDepth", 3, "Depth of callees shown for focused function.")
+	deadFlag      = flag.Bool("dead", false, "Show functions unreachable from entry points greyed out.")
+	cyclesFlag    = flag.Bool("cycles", false, "Highlight recursive functions and cycles of mutually recursive functions.")
+	collapseFlag  = flag.Bool("collapseCycles", false, "Collapse each cycle of mutually recursive functions into single node.")
+	rulesFlag     = flag.String("rules", "", "Rules file with allowed and denied calls between packages, denied calls are drawn red.")
+	baseFlag      = flag.String("base", "", "Base git revision or JSON output of previous run compared by diff command.")
+	headFlag      = flag.String("head", "", "Head git revision compared by diff command, working tree is used when empty.")
+	coverFlag     = flag.String("coverprofile", "", "Color functions by coverage from profile written by 'go test -coverprofile', calls never executed are dashed grey.")
+	pprofFlag     = flag.String("pprof", "", "Color functions and calls by cost from pprof CPU or heap profile, calls observed only at runtime are dotted.")
+	gitDiffFlag   = flag.String("gitDiff", "", "Git revision or range (e.g. main...HEAD) of changes used by impact command, changed functions and their callers are highlighted.")
+	changedFlag   = flag.String("changed", "", "Changed files (separated by comma) used by impact command, changed functions and their callers are highlighted.")
+	heatFlag      = flag.String("heat", "", "Color functions by metric [fanin | fanout | betweenness | reach] and packages by coupling.")
+	sortFlag      = flag.String("sort", statFanIn, "Sort stats tables by [name | fanin | fanout | betweenness | reach | afferent | efferent | instability]")
+	treeFlag      = flag.String("tree", treeCallees, "Tree of focused function printed in text format [callees | callers]")
+	fromFlag      = flag.String("from", "", "Show only call paths from this function, requires -to.")
+	toFlag        = flag.String("to", "", "Show only call paths to this function, requires -from.")
+	pathsFlag     = flag.Int("paths", 1, "Maximum number of call paths shown, shortest paths come first.")
+	pathLenFlag   = flag.Int("pathLen", 0, "Maximum number of calls in shown call paths (0 for unlimited).")
 	groupFlag     = flag.String("group", "pkg", "Grouping functions by packages and/or types [pkg, type] (separated by comma)")
 	limitFlag     = flag.String("limit", "", "Limit package paths to given prefixes (separated by comma)")
 	ignoreFlag    = flag.String("ignore", "", "Ignore package paths containing given prefixes (separated by comma)")
@@ -37,14 +89,22 @@ var (
 	nostdFlag     = flag.Bool("nostd", false, "Omit calls to/from packages in standard library.")
 	nointerFlag   = flag.Bool("nointer", false, "Omit calls to unexported functions.")
 	testFlag      = flag.Bool("tests", false, "Include test code.")
+	libFlag       = flag.Bool("lib", false, "Library mode: use exported functions and methods of the package as roots.")
+	workspaceFlag = flag.Bool("workspace", false, "Analyze all modules of the go.work workspace as one program.")
+	allowErrors   = flag.Bool("allow-errors", false, "Continue analysis with packages containing errors, broken packages are stubbed.")
 	graphvizFlag  = flag.Bool("graphviz", false, "Use Graphviz's dot program to render images.")
+	rendererFlag  = flag.String("renderer", "", "Renderer of SVG images [graphviz | builtin], builtin layout needs no Graphviz (default graphviz, builtin when dot is missing in static binaries)")
 	httpFlag      = flag.String("http", ":7878", "HTTP service address.")
 	skipBrowser   = flag.Bool("skipbrowser", false, "Skip opening browser.")
+	watchFlag     = flag.Bool("watch", false, "Watch source files and re-analyze on changes (server mode only).")
+	viewerFlag    = flag.Bool("viewer", false, "Open interactive viewer instead of image in browser (server mode only).")
 	outputFile    = flag.String("file", "", "output filename - omit to use server mode")
-	outputFormat  = flag.String("format", "svg", "output file format [svg | png | jpg | ...]")
+	outputFormat  = flag.String("format", "svg", "output file format [svg | png | jpg | json | mermaid | plantuml | graphml | gexf | text | ...], text is printed to stdout")
 	cacheDir      = flag.String("cacheDir", "", "Enable caching to avoid unnecessary re-rendering, you can force rendering by adding 'refresh=true' to the URL query or emptying the cache directory")
-	callgraphAlgo = flag.String("algo", string(CallGraphTypeStatic), fmt.Sprintf("The algorithm used to construct the call graph. Possible values inlcude: %q, %q, %q",
-		CallGraphTypeStatic, CallGraphTypeCha, CallGraphTypeRta))
+	cacheSizeFlag = flag.Int64("cacheSize", 256, "Maximum size of images in cache directory in MB, least recently used are evicted first (0 for unlimited)")
+	memCacheFlag  = flag.Int64("memCache", 64, "Maximum size of rendered outputs kept in memory in MB (0 to disable)")
+	callgraphAlgo = flag.String("algo", string(CallGraphTypeStatic), fmt.Sprintf("The algorithm used to construct the call graph. Possible values inlcude: %q, %q, %q, %q",
+		CallGraphTypeStatic, CallGraphTypeCha, CallGraphTypeRta, CallGraphTypeVta))
 
 	debugFlag   = flag.Bool("debug", false, "Enable verbose log.")
 	versionFlag = flag.Bool("version", false, "Show version and exit.")
@@ -66,6 +126,15 @@ func logf(f string, a ...interface{}) {
 	}
 }
 
+// flagSet reports whether the flag was given on command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
 func parseHTTPAddr(addr string) string {
 	host, port, _ := net.SplitHostPort(addr)
 	if host == "" {
@@ -88,19 +157,75 @@ func openBrowser(url string) {
 	}
 }
 
-func outputDot(fname string, outputFormat string) {
-	// get cmdline default for analysis
-	Analysis.OptsSetup()
+// loadOverlays loads files given by -rules, -coverprofile, -pprof
+// and -gitDiff or -changed flags.
+func loadOverlays() (renderOverlays, error) {
+	var (
+		o   renderOverlays
+		err error
+	)
+	if *rulesFlag != "" {
+		if o.rules, err = loadRules(*rulesFlag); err != nil {
+			return o, err
+		}
+	}
+	if *coverFlag != "" {
+		if o.cover, err = loadCoverProfile(*coverFlag); err != nil {
+			return o, err
+		}
+	}
+	if *pprofFlag != "" {
+		if o.profile, err = loadPprofProfile(*pprofFlag); err != nil {
+			return o, err
+		}
+	}
+	if *gitDiffFlag != "" {
+		o.changes, err = gitChanges(*gitDiffFlag)
+	} else if *changedFlag != "" {
+		o.changes, err = changedFiles(strings.Split(*changedFlag, ","))
+	}
+	return o, err
+}
 
-	if e := Analysis.ProcessListArgs(); e != nil {
+// cmdlineOpts returns render options given by command line flags.
+func cmdlineOpts(a *analysis, overlays renderOverlays) *renderOpts {
+	opts := a.OptsSetup(overlays)
+	if e := opts.ProcessListArgs(); e != nil {
 		log.Fatalf("%v\n", e)
 	}
+	return opts
+}
 
-	output, err := Analysis.Render()
+func outputDot(fname string, overlays renderOverlays) {
+	a := Analysis.Load()
+	opts := cmdlineOpts(a, overlays)
+
+	output, err := a.Render(opts)
 	if err != nil {
 		log.Fatalf("%v\n", err)
 	}
 
+	writeOutput(fname, opts, output)
+}
+
+// writeOutput writes rendered output to file, images are converted from DOT.
+func writeOutput(fname string, opts *renderOpts, output []byte) {
+	outputFormat := opts.format
+	if outputFormat == textFormat {
+		os.Stdout.Write(output)
+		return
+	}
+
+	if f, ok := opts.graphFormat(); ok {
+		log.Printf("writing %s output", outputFormat)
+
+		writeErr := os.WriteFile(fmt.Sprintf("%s.%s", fname, f.ext), output, 0644)
+		if writeErr != nil {
+			log.Fatalf("%v\n", writeErr)
+		}
+		return
+	}
+
 	log.Println("writing dot output")
 
 	writeErr := os.WriteFile(fmt.Sprintf("%s.gv", fname), output, 0755)
@@ -110,15 +235,222 @@ func outputDot(fname string, outputFormat string) {
 
 	log.Printf("converting dot to %s\n", outputFormat)
 
-	_, err = dotToImage(fname, outputFormat, output)
+	_, err := dotToImage(fname, outputFormat, output)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+}
+
+func outputDeadCode(outputFormat string) {
+	dead, err := Analysis.Load().DeadCode()
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	if outputFormat == "json" {
+		err = writeDeadCodeJSON(os.Stdout, dead)
+	} else {
+		err = printDeadCode(os.Stdout, dead)
+	}
 	if err != nil {
 		log.Fatalf("%v\n", err)
 	}
 }
 
+func outputStats(outputFormat string, overlays renderOverlays) {
+	a := Analysis.Load()
+	opts := cmdlineOpts(a, overlays)
+
+	st, err := a.Stats(opts)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	st.Sort(*sortFlag)
+
+	switch outputFormat {
+	case "csv":
+		err = st.WriteCSV(os.Stdout)
+	case "json":
+		err = st.WriteJSON(os.Stdout)
+	default:
+		err = st.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+}
+
+func outputCycles(overlays renderOverlays) {
+	a := Analysis.Load()
+	opts := cmdlineOpts(a, overlays)
+
+	cycles, err := a.Cycles(opts)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	os.Stdout.Write(printCycles(cycles, opts.edgeFilter()))
+}
+
+// outputViolations prints calls denied by the rules,
+// exit code is non-zero if there are any.
+func outputViolations(overlays renderOverlays) {
+	a := Analysis.Load()
+	opts := cmdlineOpts(a, overlays)
+
+	violations, err := a.CheckRules(opts)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	os.Stdout.Write(printViolations(opts.overlays.rules, violations, opts.edgeFilter()))
+	if len(violations) > 0 {
+		log.Printf("%d calls violate rules", len(violations))
+		os.Exit(1)
+	}
+}
+
+func outputImpact(outputFormat string, overlays renderOverlays) {
+	a := Analysis.Load()
+	opts := cmdlineOpts(a, overlays)
+
+	impact, err := a.Impact(opts)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	if outputFormat == "json" {
+		err = impact.WriteJSON(os.Stdout)
+	} else {
+		err = impact.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+}
+
+// outputDiff prints summary of changes between base and head revisions,
+// merged graph of both revisions is written to the output file if given.
+func outputDiff(algo CallGraphType, args []string, overlays renderOverlays) {
+	// revisions are analyzed in temporary worktrees, their
+	// snapshots would never be used again, so they are not cached
+	analyzeRevision := func(rev string) (*analysis, error) {
+		a := new(analysis)
+		if rev == "" {
+			return a, a.DoAnalysis(algo, "", *testFlag, *libFlag, *allowErrors, *cacheDir, args)
+		}
+		w, err := checkoutRevision(rev)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Remove()
+		if err := a.DoAnalysis(algo, w.Dir, *testFlag, *libFlag, *allowErrors, "", args); err != nil {
+			return nil, fmt.Errorf("%s: %v", rev, err)
+		}
+		graph, err := a.CallGraph(algo, "")
+		if err != nil {
+			return nil, err
+		}
+		graph.relocate(w.Root, w.Top)
+		return a, nil
+	}
+
+	var base *callGraph
+	partial := false
+	if fi, err := os.Stat(*baseFlag); err == nil && !fi.IsDir() {
+		partial = true
+		if base, err = loadJSONGraph(*baseFlag); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+	} else {
+		b, err := analyzeRevision(*baseFlag)
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+		if base, err = b.CallGraph(algo, ""); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+	}
+
+	a, err := analyzeRevision(*headFlag)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	Analysis.Store(a)
+
+	opts := cmdlineOpts(a, overlays)
+	// changes in the whole program are shown, unless focus is given
+	if !flagSet("focus") {
+		opts.focus = ""
+	}
+
+	head, err := a.CallGraph(opts.algo, "")
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	headName := *headFlag
+	if headName == "" {
+		headName = "working tree"
+	}
+	opts.diff = diffGraphs(base, head, *baseFlag, headName)
+	opts.diff.Partial = partial
+
+	os.Stdout.Write(printDiff(opts.diff, opts.edgeFilter()))
+
+	if *outputFile == "" {
+		return
+	}
+	output, err := a.Render(opts)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	writeOutput(*outputFile, opts, output)
+}
+
+// command runs on packages given by args, after flags are parsed.
+type command func(algo CallGraphType, args []string, overlays renderOverlays)
+
+// commands are selected by the first argument.
+var commands = map[string]command{
+	"deadcode": afterAnalysis(func(renderOverlays) { outputDeadCode(*outputFormat) }),
+	"stats":    afterAnalysis(func(o renderOverlays) { outputStats(*outputFormat, o) }),
+	"cycles":   afterAnalysis(outputCycles),
+	"check":    afterAnalysis(outputViolations),
+	"impact":   afterAnalysis(func(o renderOverlays) { outputImpact(*outputFormat, o) }),
+	"diff":     outputDiff,
+}
+
+// afterAnalysis returns command printing output of the analysis.
+func afterAnalysis(output func(overlays renderOverlays)) command {
+	return func(algo CallGraphType, args []string, overlays renderOverlays) {
+		if err := analyze(algo, args); err != nil {
+			log.Fatal(err)
+		}
+		output(overlays)
+	}
+}
+
+// analyze runs analysis of the packages with options given by flags.
+func analyze(algo CallGraphType, args []string) error {
+	a := &analysis{progress: analysisProgress}
+	err := a.DoAnalysis(algo, "", *testFlag, *libFlag, *allowErrors, *cacheDir, args)
+	analysisProgress.Finish(err)
+	if err != nil {
+		return err
+	}
+	Analysis.Store(a)
+	return nil
+}
+
 //noinspection GoUnhandledErrorResult
 func main() {
-	flag.Parse()
+	var command string
+	if len(os.Args) > 1 && commands[os.Args[1]] != nil {
+		command = os.Args[1]
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 
 	if *versionFlag {
 		fmt.Fprintln(os.Stderr, Version())
@@ -128,28 +460,94 @@ func main() {
 		log.SetFlags(log.Lmicroseconds)
 	}
 
-	if flag.NArg() != 1 {
+	args := flag.Args()
+	if *workspaceFlag {
+		patterns, err := workspacePatterns("")
+		if err != nil {
+			log.Fatal(err)
+		}
+		args = append(args, patterns...)
+	}
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, Usage)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if command == "stats" && !isSortKey(*sortFlag) {
+		fmt.Fprintf(os.Stderr, "invalid sort key: %s\n\n", *sortFlag)
 		fmt.Fprint(os.Stderr, Usage)
 		flag.PrintDefaults()
 		os.Exit(2)
 	}
-
-	args := flag.Args()
-	tests := *testFlag
 	httpAddr := *httpFlag
 	urlAddr := parseHTTPAddr(httpAddr)
 
-	Analysis = new(analysis)
-	if err := Analysis.DoAnalysis(CallGraphType(*callgraphAlgo), "", tests, args); err != nil {
+	renderCache = newOutputCache(*memCacheFlag << 20)
+
+	overlays, err := loadOverlays()
+	if err != nil {
 		log.Fatal(err)
 	}
+	if command == "check" && *rulesFlag == "" {
+		log.Fatal("check requires rules file given by -rules flag")
+	}
+	if command == "impact" && *gitDiffFlag == "" && *changedFlag == "" {
+		log.Fatal("impact requires changes given by -gitDiff or -changed flag")
+	}
+	if command == "diff" && *baseFlag == "" {
+		log.Fatal("diff requires base revision or file given by -base flag")
+	}
 
-	http.HandleFunc("/", handler)
+	algo := CallGraphType(*callgraphAlgo)
+	switch {
+	case command == "deadcode" && flagSet("algo") && algo != CallGraphTypeRta:
+		log.Fatalf("deadcode finds unreachable functions using %s, -algo=%s is not supported", CallGraphTypeRta, algo)
+	case command == "deadcode":
+		// dead code is found using RTA
+		algo = CallGraphTypeRta
+	case command == "impact" && !flagSet("algo"):
+		// static call graph misses calls through interfaces and function
+		// values, tests calling changed code that way would not be run
+		algo = CallGraphTypeCha
+	case command == "impact" && algo == CallGraphTypeStatic:
+		log.Printf("warning: static call graph misses callers through interfaces and function values, not all affected tests are selected")
+	}
 
-	if *outputFile == "" {
+	if run, ok := commands[command]; ok {
+		run(algo, args, overlays)
+	} else if *outputFile == "" && *outputFormat != textFormat {
 		*outputFile = "output"
+
+		handler := &graphHandler{overlays: overlays}
+		http.Handle("/", handler)
+		http.Handle("/path", handler)
+		http.Handle("/progress", analysisProgress)
+		http.Handle(viewerPath, viewerHandler())
+
+		reloads := newReloadNotifier()
+		if *watchFlag {
+			http.Handle("/events", reloads)
+		}
+
+		// analysis runs in background, until it is done
+		// the server responds with page showing its progress
+		go func() {
+			if err := analyze(algo, args); err != nil {
+				log.Printf("analysis failed: %v", err)
+				return
+			}
+			if *watchFlag {
+				watchSources(reloads)
+			}
+		}()
+
 		if !*skipBrowser {
-			go openBrowser(urlAddr)
+			if *viewerFlag {
+				go openBrowser(urlAddr + viewerPath)
+			} else {
+				go openBrowser(urlAddr)
+			}
 		}
 
 		log.Printf("http serving at %s", urlAddr)
@@ -158,6 +556,9 @@ func main() {
 			log.Fatal(err)
 		}
 	} else {
-		outputDot(*outputFile, *outputFormat)
+		if err := analyze(algo, args); err != nil {
+			log.Fatal(err)
+		}
+		outputDot(*outputFile, overlays)
 	}
 }
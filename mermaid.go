@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func mermaidLabel(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+func (g *dotGraph) WriteMermaid(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	direction := g.Options["rankdir"]
+	if direction == "" {
+		direction = "LR"
+	}
+	fmt.Fprintf(bw, "flowchart %s\n", direction)
+
+	ids := make(map[*dotNode]string)
+	var styles []string
+
+	writeNode := func(n *dotNode, indent string) {
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[n] = id
+		fmt.Fprintf(bw, "%s%s[\"%s\"]\n", indent, id, mermaidLabel(n.Attrs["label"]))
+		if fill := n.Attrs["fillcolor"]; fill != "" {
+			styles = append(styles, fmt.Sprintf("style %s fill:%s", id, fill))
+		}
+	}
+
+	clusters := 0
+	var writeCluster func(c *dotCluster, indent string)
+	writeCluster = func(c *dotCluster, indent string) {
+		inner := indent
+		// root cluster is drawn only when it represents focused package
+		if label := c.Attrs["label"]; c != g.Cluster || label != "" {
+			fmt.Fprintf(bw, "%ssubgraph c%d[\"%s\"]\n", indent, clusters, mermaidLabel(label))
+			clusters++
+			inner = indent + "    "
+		}
+		for _, n := range c.sortedNodes() {
+			writeNode(n, inner)
+		}
+		for _, sub := range c.sortedClusters() {
+			writeCluster(sub, inner)
+		}
+		if inner != indent {
+			fmt.Fprintf(bw, "%send\n", indent)
+		}
+	}
+	if g.Cluster != nil {
+		writeCluster(g.Cluster, "    ")
+	}
+	for _, n := range g.Nodes {
+		writeNode(n, "    ")
+	}
+
+	for _, e := range g.sortedEdges() {
+		arrow := "-->"
+		// dynamic calls
+		if e.Kind == callKindDynamic {
+			arrow = "-.->"
+		}
+		// go & defer calls
+		if e.Kind == callKindGo || e.Kind == callKindDefer {
+			arrow = fmt.Sprintf("%s|%s|", arrow, e.Kind)
+		}
+		fmt.Fprintf(bw, "    %s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+
+	for _, s := range styles {
+		fmt.Fprintf(bw, "    %s\n", s)
+	}
+
+	return bw.Flush()
+}
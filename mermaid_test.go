@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testDotGraph().WriteMermaid(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"direction", "flowchart LR\n"},
+		{"focus cluster", `subgraph c0["main"]`},
+		{"package cluster", `subgraph c1["pkg"]`},
+		{"type cluster", `subgraph c2["(*T)"]`},
+		{"multiline label", `n1["pkg<br>Run"]`},
+		{"dashed static call", "n0 --> n1\n"},
+		{"bold dynamic call", "n1 -.-> n2\n"},
+		{"go call", "n0 -->|go| n2\n"},
+		{"fill", "style n0 fill:lightblue\n"},
+	} {
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("%s: output does not contain %q:\n%s", tt.name, tt.want, out)
+		}
+	}
+}
+
+func TestMermaidLabel(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"main", "main"},
+		{"pkg\nRun", "pkg<br>Run"},
+		{`say "hi"`, "say #quot;hi#quot;"},
+	} {
+		if got := mermaidLabel(tt.in); got != tt.want {
+			t.Errorf("mermaidLabel(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
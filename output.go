@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"go/build"
-	"go/types"
+	"go/token"
+	"io"
+	"math"
+	"net/url"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"golang.org/x/tools/go/callgraph"
@@ -15,13 +19,20 @@ import (
 func isSynthetic(edge *callgraph.Edge) bool {
 	// TODO: consider handling callee.Func.Pkg == nil
 	// this could still generate a node for the call, might be useful
-	return edge.Caller.Func.Pkg == nil || edge.Callee.Func.Pkg == nil || edge.Callee.Func.Synthetic != ""
+	return edge.Caller.Func.Pkg == nil || edge.Callee.Func.Pkg == nil ||
+		(edge.Callee.Func.Synthetic != "" && !isStub(edge.Callee.Func))
 }
 
-func inStd(node *callgraph.Node) bool {
-	//pkg, _ := build.Import(node.Func.Pkg.Pkg.Path(), "", 0)
+// isStub reports whether function was created only from type information,
+// this happens for packages containing errors, see partialPackages.
+func isStub(fn *ssa.Function) bool {
+	return fn.Synthetic == "from type information"
+}
+
+func inStd(node *funcNode) bool {
+	//pkg, _ := build.Import(node.Pkg, "", 0)
 	//return pkg.Goroot
-	return isStdPkgPath(node.Func.Pkg.Pkg.Path())
+	return isStdPkgPath(node.Pkg)
 }
 
 func isStdPkgPath(path string) bool {
@@ -31,20 +42,192 @@ func isStdPkgPath(path string) bool {
 	return true
 }
 
+type callKind string
+
+const (
+	callKindStatic  callKind = "static"
+	callKindDynamic callKind = "dynamic"
+	callKindGo      callKind = "go"
+	callKindDefer   callKind = "defer"
+
+	// observed in runtime profile, missing in the call graph
+	callKindObserved callKind = "observed"
+)
+
+// graphFormat is an output format written directly from the graph,
+// formats not listed in graphFormats are rendered by Graphviz from DOT.
+type graphFormat struct {
+	ext         string
+	contentType string
+	write       func(*dotGraph, io.Writer) error
+}
+
+// textFormat is plain text output for terminals,
+// used for paths and trees of focused functions.
+const textFormat = "text"
+
+var graphFormats = map[string]graphFormat{
+	"json":     {"json", "application/json", (*dotGraph).WriteJSON},
+	"mermaid":  {"mmd", "text/plain; charset=utf-8", (*dotGraph).WriteMermaid},
+	"plantuml": {"puml", "text/plain; charset=utf-8", (*dotGraph).WritePlantUML},
+	"graphml":  {"graphml", "application/xml", (*dotGraph).WriteGraphML},
+	"gexf":     {"gexf", "application/xml", (*dotGraph).WriteGEXF},
+}
+
+// builtinSVG is SVG image written using built-in layout instead of Graphviz.
+var builtinSVG = graphFormat{"svg", "image/svg+xml", (*dotGraph).WriteSVG}
+
+// graphFormat returns format written directly from the graph,
+// false is returned for formats rendered by Graphviz from DOT.
+func (opts *renderOpts) graphFormat() (graphFormat, bool) {
+	if opts.format == "svg" && opts.renderer == rendererBuiltin {
+		return builtinSVG, true
+	}
+	if opts.format == "json" && opts.layout {
+		return graphFormat{"json", "application/json", jsonWithLayout(opts.collapsed)}, true
+	}
+	f, ok := graphFormats[opts.format]
+	return f, ok
+}
+
+// ==[ type def/func: edgeFilter ]==============================================
+
+// edgeFilter omits calls using options shared by all outputs.
+type edgeFilter struct {
+	limitPaths   []string
+	ignorePaths  []string
+	includePaths []string
+	nostd        bool
+	nointer      bool
+}
+
+func hasPrefix(node *funcNode, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(node.Pkg, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInter(edge *callEdge) bool {
+	callee := edge.callee
+	if callee.HasObject && !callee.Exported {
+		return true
+	}
+	return false
+}
+
+// omit reports whether the call should be omitted from output.
+func (f *edgeFilter) omit(edge *callEdge) bool {
+	caller := edge.caller
+	callee := edge.callee
+
+	// omit std
+	if f.nostd && (inStd(caller) || inStd(callee)) {
+		return true
+	}
+
+	// omit inter
+	if f.nointer && isInter(edge) {
+		return true
+	}
+
+	// include path prefixes
+	if len(f.includePaths) > 0 &&
+		(hasPrefix(caller, f.includePaths) || hasPrefix(callee, f.includePaths)) {
+		logf("include: %s -> %s", caller, callee)
+		return false
+	}
+
+	// limit path prefixes
+	if len(f.limitPaths) > 0 &&
+		(!hasPrefix(caller, f.limitPaths) || !hasPrefix(callee, f.limitPaths)) {
+		logf("NOT in limit: %s -> %s", caller, callee)
+		return true
+	}
+
+	// ignore path prefixes
+	if len(f.ignorePaths) > 0 &&
+		(hasPrefix(caller, f.ignorePaths) || hasPrefix(callee, f.ignorePaths)) {
+		logf("IS ignored: %s -> %s", caller, callee)
+		return true
+	}
+
+	return false
+}
+
+// neighborEdges returns calls reachable from the function
+// within given depths of callers and callees.
+func neighborEdges(fn *funcNode, callerDepth, calleeDepth int, filter *edgeFilter) map[*callEdge]bool {
+	edges := make(map[*callEdge]bool)
+	walk := func(depth int, next func(*funcNode) []*callEdge, other func(*callEdge) *funcNode) {
+		visited := map[*funcNode]bool{fn: true}
+		queue := []*funcNode{fn}
+		for d := 0; d < depth && len(queue) > 0; d++ {
+			var nextQueue []*funcNode
+			for _, f := range queue {
+				for _, e := range next(f) {
+					if filter.omit(e) {
+						continue
+					}
+					edges[e] = true
+					if n := other(e); !visited[n] {
+						visited[n] = true
+						nextQueue = append(nextQueue, n)
+					}
+				}
+			}
+			queue = nextQueue
+		}
+	}
+	walk(calleeDepth,
+		func(f *funcNode) []*callEdge { return f.out },
+		func(e *callEdge) *funcNode { return e.callee })
+	walk(callerDepth,
+		func(f *funcNode) []*callEdge { return f.in },
+		func(e *callEdge) *funcNode { return e.caller })
+	return edges
+}
+
+// markBroken sets style of cluster for package containing errors.
+func markBroken(attrs dotAttrs, p *graphPackage) {
+	attrs["style"] = "filled,dashed"
+	attrs["fillcolor"] = "#ffe0e0"
+	attrs["pencolor"] = "#cc0000"
+	attrs["penwidth"] = "1.5"
+	attrs["tooltip"] = fmt.Sprintf("package: %s\nerrors:\n%s", p.Path, strings.Join(p.Errors, "\n"))
+}
+
+// graphOverlays holds data drawn over the rendered call graph,
+// nil fields are not drawn.
+type graphOverlays struct {
+	dead       []*funcNode // unreachable functions, empty if none
+	deadURL    string      // link toggling unreachable functions
+	stats      *graphStats
+	heat       string // metric coloring functions by stats
+	cycles     []*callCycle
+	collapse   bool // cycles are collapsed into single nodes
+	violations map[*callEdge]*archRule
+	diff       *graphDiff
+	cover      *coverProfile
+	costs      *profileCosts
+	impact     *impactSet
+}
+
 func printOutput(
-	prog *ssa.Program,
-	mainPkg *ssa.Package,
-	cg *callgraph.Graph,
-	focusPkg *types.Package,
-	limitPaths,
-	ignorePaths,
-	includePaths []string,
+	cg *callGraph,
+	mainPkg string,
+	focusPkg *graphPackage,
+	focusFuncs []*funcNode,
+	focusEdges map[*callEdge]bool,
+	filter *edgeFilter,
 	groupBy []string,
-	nostd,
-	nointer bool,
+	overlays *graphOverlays,
+	write func(*dotGraph, io.Writer) error,
 ) ([]byte, error) {
 
-	logf("printing output for: %v", focusPkg)
+	logf("printing output for: %+v %v", focusPkg, focusFuncs)
 	logf("src dirs: %+v, default build context: %+v", build.Default.SrcDirs(), build.Default)
 
 	var groupType, groupPkg bool
@@ -67,7 +250,34 @@ func printOutput(
 	}
 	if focusPkg != nil {
 		cluster.Attrs["bgcolor"] = "#e6ecfa"
-		cluster.Attrs["label"] = focusPkg.Name()
+		cluster.Attrs["label"] = focusPkg.Name
+		if overlays.cover != nil {
+			if s, ok := overlays.cover.Package(focusPkg.Path); ok {
+				cluster.Attrs["tooltip"] = fmt.Sprintf("package: %s\ncoverage: %.1f%% of statements", focusPkg.Path, s.Percent())
+			}
+		}
+		if len(focusPkg.Errors) > 0 {
+			markBroken(cluster.Attrs, focusPkg)
+		}
+	}
+	if len(focusFuncs) > 0 {
+		cluster.Attrs["bgcolor"] = "#e6ecfa"
+		cluster.Attrs["label"] = focusFuncs[0].PkgName
+		for _, f := range focusFuncs {
+			if f.Pkg != focusFuncs[0].Pkg {
+				cluster.Attrs["label"] = ""
+			}
+		}
+	}
+
+	// packages with errors, only present in partial programs
+	broken := make(map[string]*graphPackage)
+	var errs []string
+	for _, p := range cg.Packages {
+		if len(p.Errors) > 0 {
+			broken[p.Path] = p
+			errs = append(errs, fmt.Sprintf("%s: %s", p.Path, p.Errors[0]))
+		}
 	}
 
 	var (
@@ -78,29 +288,27 @@ func printOutput(
 	nodeMap := make(map[string]*dotNode)
 	edgeMap := make(map[string]*dotEdge)
 
-	cg.DeleteSyntheticNodes()
+	logf("%d limit prefixes: %v", len(filter.limitPaths), filter.limitPaths)
+	logf("%d ignore prefixes: %v", len(filter.ignorePaths), filter.ignorePaths)
+	logf("%d include prefixes: %v", len(filter.includePaths), filter.includePaths)
+	logf("no std packages: %v", filter.nostd)
 
-	logf("%d limit prefixes: %v", len(limitPaths), limitPaths)
-	logf("%d ignore prefixes: %v", len(ignorePaths), ignorePaths)
-	logf("%d include prefixes: %v", len(includePaths), includePaths)
-	logf("no std packages: %v", nostd)
-
-	var isFocused = func(edge *callgraph.Edge) bool {
-		caller := edge.Caller
-		callee := edge.Callee
-		if focusPkg != nil && (caller.Func.Pkg.Pkg.Path() == focusPkg.Path() || callee.Func.Pkg.Pkg.Path() == focusPkg.Path()) {
+	var isFocused = func(edge *callEdge) bool {
+		caller := edge.caller
+		callee := edge.callee
+		if focusPkg != nil && (caller.Pkg == focusPkg.Path || callee.Pkg == focusPkg.Path) {
 			return true
 		}
 		fromFocused := false
-		for _, e := range caller.In {
-			if !isSynthetic(e) && focusPkg != nil && e.Caller.Func.Pkg.Pkg.Path() == focusPkg.Path() {
+		for _, e := range caller.in {
+			if focusPkg != nil && e.caller.Pkg == focusPkg.Path {
 				fromFocused = true
 				break
 			}
 		}
 		toFocused := false
-		for _, e := range callee.Out {
-			if !isSynthetic(e) && focusPkg != nil && e.Callee.Func.Pkg.Pkg.Path() == focusPkg.Path() {
+		for _, e := range callee.out {
+			if focusPkg != nil && e.callee.Pkg == focusPkg.Path {
 				toFocused = true
 				break
 			}
@@ -112,299 +320,415 @@ func printOutput(
 		return false
 	}
 
-	var inIncludes = func(node *callgraph.Node) bool {
-		pkgPath := node.Func.Pkg.Pkg.Path()
-		for _, p := range includePaths {
-			if strings.HasPrefix(pkgPath, p) {
-				return true
-			}
-		}
-		return false
-	}
-
-	var inLimits = func(node *callgraph.Node) bool {
-		pkgPath := node.Func.Pkg.Pkg.Path()
-		for _, p := range limitPaths {
-			if strings.HasPrefix(pkgPath, p) {
-				return true
-			}
-		}
-		return false
-	}
-
-	var inIgnores = func(node *callgraph.Node) bool {
-		pkgPath := node.Func.Pkg.Pkg.Path()
-		for _, p := range ignorePaths {
-			if strings.HasPrefix(pkgPath, p) {
-				return true
-			}
-		}
-		return false
-	}
-
-	var isInter = func(edge *callgraph.Edge) bool {
-		//caller := edge.Caller
-		callee := edge.Callee
-		if callee.Func.Object() != nil && !callee.Func.Object().Exported() {
-			return true
+	var sprintNode = func(node *funcNode) *dotNode {
+		// only once
+		key := node.ID
+		if n, ok := nodeMap[key]; ok {
+			return n
 		}
-		return false
-	}
 
-	count := 0
-	err := callgraph.GraphVisitEdges(cg, func(edge *callgraph.Edge) error {
-		count++
+		fileNode := fmt.Sprintf("%s:%d", filepath.Base(node.Pos.Filename), node.Pos.Line)
+		nodeTooltip := fmt.Sprintf("%s | defined in %s", node.ID, fileNode)
 
-		caller := edge.Caller
-		callee := edge.Callee
+		// is focused
+		isFocused := (focusPkg != nil && node.Pkg == focusPkg.Path) ||
+			slices.Contains(focusFuncs, node)
+		attrs := make(dotAttrs)
 
-		posCaller := prog.Fset.Position(caller.Func.Pos())
-		posCallee := prog.Fset.Position(callee.Func.Pos())
-		posEdge := prog.Fset.Position(edge.Pos())
-		//fileCaller := fmt.Sprintf("%s:%d", posCaller.Filename, posCaller.Line)
-		filenameCaller := filepath.Base(posCaller.Filename)
+		// node label
+		label := node.Name
 
-		// omit synthetic calls
-		if isSynthetic(edge) {
-			return nil
+		// omit type from label
+		if groupType && node.Recv != "" {
+			parts := strings.Split(label, ".")
+			label = parts[len(parts)-1]
 		}
 
-		//logf(" - %s -> %s (%s -> %s) %v\n", caller.Func.Pkg, callee.Func.Pkg, caller, callee, filenameCaller)
-
-		callerPkg := caller.Func.Pkg.Pkg
-		calleePkg := callee.Func.Pkg.Pkg
+		pkgPath := node.Pkg
+		isStdPkg := isStdPkgPath(pkgPath)
 
-		// focus specific pkg
-		if focusPkg != nil &&
-			!isFocused(edge) {
-			return nil
+		// set node color
+		if isFocused {
+			attrs["fillcolor"] = "lightblue"
+		} else if isStdPkg {
+			attrs["fillcolor"] = "#adedad"
+		} else {
+			attrs["fillcolor"] = "moccasin"
 		}
 
-		// omit std
-		if nostd && (inStd(caller) || inStd(callee)) {
-			return nil
+		// include pkg name
+		if !groupPkg && !isFocused {
+			label = fmt.Sprintf("%s\n%s", node.PkgName, label)
 		}
 
-		// omit inter
-		if nointer && isInter(edge) {
-			return nil
+		attrs["label"] = label
+
+		// heat overlay
+		if overlays.stats != nil {
+			attrs["fillcolor"] = heatColor(overlays.stats.heat(node, overlays.heat))
+			if s, ok := overlays.stats.byFunc[node]; ok {
+				nodeTooltip = fmt.Sprintf("%s\nfan-in: %d, fan-out: %d, betweenness: %.1f, reach: %d",
+					nodeTooltip, s.FanIn, s.FanOut, s.Betweenness, s.Reach)
+			}
 		}
 
-		include := false
-		// include path prefixes
-		if len(includePaths) > 0 &&
-			(inIncludes(caller) || inIncludes(callee)) {
-			logf("include: %s -> %s", caller, callee)
-			include = true
+		// coverage overlay
+		if overlays.cover != nil {
+			if s := overlays.cover.Func(node); s.Total > 0 {
+				attrs["fillcolor"] = coverColor(s.Percent())
+				nodeTooltip = fmt.Sprintf("%s\ncoverage: %.1f%% (%d/%d statements)",
+					nodeTooltip, s.Percent(), s.Covered, s.Total)
+			}
 		}
 
-		if !include {
-			// limit path prefixes
-			if len(limitPaths) > 0 &&
-				(!inLimits(caller) || !inLimits(callee)) {
-				logf("NOT in limit: %s -> %s", caller, callee)
-				return nil
+		// runtime profile overlay, size shows flat cost like in pprof
+		if overlays.costs != nil {
+			if flat, cum := overlays.costs.Func(node); cum > 0 {
+				attrs["fillcolor"] = heatColor(float64(cum) / float64(overlays.costs.maxCum))
+				attrs["fontsize"] = fmt.Sprintf("%.1f", 8+16*math.Sqrt(float64(flat)/float64(overlays.costs.maxFlat)))
+				nodeTooltip = fmt.Sprintf("%s\n%s flat: %s (%.1f%%), cum: %s (%.1f%%)", nodeTooltip, overlays.costs.Profile.Type,
+					overlays.costs.Profile.Format(flat), overlays.costs.percent(flat), overlays.costs.Profile.Format(cum), overlays.costs.percent(cum))
 			}
+		}
 
-			// ignore path prefixes
-			if len(ignorePaths) > 0 &&
-				(inIgnores(caller) || inIgnores(callee)) {
-				logf("IS ignored: %s -> %s", caller, callee)
-				return nil
+		// changed functions and their callers
+		if overlays.impact != nil && overlays.impact.Affected[node] {
+			attrs["color"] = "#e67e00"
+			attrs["penwidth"] = "2.0"
+			if overlays.impact.Changed[node] {
+				attrs["fillcolor"] = "#ffb347"
+				nodeTooltip = fmt.Sprintf("%s\nchanged in %s", nodeTooltip, overlays.impact.Source)
+			} else {
+				attrs["fillcolor"] = "#ffe0b2"
+				nodeTooltip = fmt.Sprintf("%s\naffected by changes in %s", nodeTooltip, overlays.impact.Source)
 			}
 		}
 
-		//var buf bytes.Buffer
-		//data, _ := json.MarshalIndent(caller.Func, "", " ")
-		//logf("call node: %s -> %s\n %v", caller, callee, string(data))
-		logf("call node: %s -> %s (%s -> %s) %v\n", caller.Func.Pkg, callee.Func.Pkg, caller, callee, filenameCaller)
+		// func styles
+		if node.Closure {
+			attrs["style"] = "dotted,filled"
+		} else if node.Exported {
+			attrs["penwidth"] = "1.5"
+		} else {
+			attrs["penwidth"] = "0.5"
+		}
 
-		var sprintNode = func(node *callgraph.Node, isCaller bool) *dotNode {
-			// only once
-			key := node.Func.String()
-			nodeTooltip := ""
+		// changes between revisions
+		if overlays.diff != nil {
+			switch {
+			case overlays.diff.AddedFuncs[node]:
+				attrs["fillcolor"] = "#b7ebb7"
+				attrs["color"] = "#2ca02c"
+				attrs["penwidth"] = "2.0"
+				nodeTooltip = fmt.Sprintf("%s\nadded since %s", nodeTooltip, overlays.diff.Base)
+			case overlays.diff.RemovedFuncs[node]:
+				attrs["fillcolor"] = "#f7c1c1"
+				attrs["color"] = "#d62728"
+				attrs["penwidth"] = "2.0"
+				nodeTooltip = fmt.Sprintf("%s\nremoved since %s", nodeTooltip, overlays.diff.Base)
+			default:
+				attrs["fillcolor"] = "#f4f4f4"
+				attrs["fontcolor"] = "#a0a0a0"
+				attrs["color"] = "#c8c8c8"
+			}
+		}
 
-			fileCaller := fmt.Sprintf("%s:%d", filepath.Base(posCaller.Filename), posCaller.Line)
-			fileCallee := fmt.Sprintf("%s:%d", filepath.Base(posCallee.Filename), posCallee.Line)
+		c := cluster
 
-			if isCaller {
-				nodeTooltip = fmt.Sprintf("%s | defined in %s", node.Func.String(), fileCaller)
-			} else {
-				nodeTooltip = fmt.Sprintf("%s | defined in %s", node.Func.String(), fileCallee)
+		// group by pkg
+		if groupPkg && !isFocused && node.Pkg != "" {
+			label := node.PkgName
+			if isStdPkg {
+				label = node.Pkg
 			}
+			key := node.Pkg
+			if _, ok := c.Clusters[key]; !ok {
+				c.Clusters[key] = &dotCluster{
+					ID:       key,
+					Kind:     "package",
+					Clusters: make(map[string]*dotCluster),
+					Attrs: dotAttrs{
+						"penwidth":  "0.8",
+						"fontsize":  "16",
+						"label":     label,
+						"style":     "filled",
+						"fillcolor": "lightyellow",
+						"URL":       fmt.Sprintf("/?f=%s", key),
+						"fontname":  "Tahoma bold",
+						"tooltip":   fmt.Sprintf("package: %s", key),
+						"rank":      "sink",
+					},
+				}
+				if isStdPkg {
+					c.Clusters[key].Attrs["fillcolor"] = "#E0FFE1"
+				}
+				if overlays.stats != nil {
+					// lighter than nodes, so they stay readable
+					c.Clusters[key].Attrs["fillcolor"] = heatColor(overlays.stats.pkgHeat(key) / 2)
+					if s, ok := overlays.stats.byPkg[key]; ok {
+						c.Clusters[key].Attrs["tooltip"] = fmt.Sprintf("package: %s\nafferent: %d, efferent: %d, instability: %.2f",
+							key, s.Afferent, s.Efferent, s.Instability)
+					}
+				}
+				if overlays.cover != nil {
+					if s, ok := overlays.cover.Package(key); ok {
+						c.Clusters[key].Attrs["tooltip"] = fmt.Sprintf("%s\ncoverage: %.1f%% of statements",
+							c.Clusters[key].Attrs["tooltip"], s.Percent())
+					}
+				}
+				if p, ok := broken[key]; ok {
+					markBroken(c.Clusters[key].Attrs, p)
+				}
+			}
+			c = c.Clusters[key]
+		}
 
-			if n, ok := nodeMap[key]; ok {
-				return n
+		// group by type
+		if groupType && node.Recv != "" {
+			label := strings.Split(node.Name, ".")[0]
+			key := node.Recv
+			if _, ok := c.Clusters[key]; !ok {
+				c.Clusters[key] = &dotCluster{
+					ID:       key,
+					Kind:     "type",
+					Clusters: make(map[string]*dotCluster),
+					Attrs: dotAttrs{
+						"penwidth":  "0.5",
+						"fontsize":  "15",
+						"fontcolor": "#222222",
+						"label":     label,
+						"labelloc":  "b",
+						"style":     "rounded,filled",
+						"fillcolor": "wheat2",
+						"tooltip":   fmt.Sprintf("type: %s", key),
+					},
+				}
+				if isFocused {
+					c.Clusters[key].Attrs["fillcolor"] = "lightsteelblue"
+				} else if isStdPkg {
+					c.Clusters[key].Attrs["fillcolor"] = "#c2e3c2"
+				}
 			}
+			c = c.Clusters[key]
+		}
 
-			// is focused
-			isFocused := focusPkg != nil &&
-				node.Func.Pkg.Pkg.Path() == focusPkg.Path()
-			attrs := make(dotAttrs)
+		attrs["tooltip"] = nodeTooltip
+		attrs["URL"] = fmt.Sprintf("/?f=%s", url.QueryEscape(node.ID))
+
+		n := &dotNode{
+			ID:       node.ID,
+			Attrs:    attrs,
+			Name:     node.Name,
+			Pkg:      pkgPath,
+			Exported: node.Exported,
+			Focused:  isFocused,
+			Pos:      node.Pos,
+		}
+		if !node.Closure {
+			n.Recv = node.Recv
+		}
 
-			// node label
-			label := node.Func.RelString(node.Func.Pkg.Pkg)
+		if c != nil {
+			c.Nodes = append(c.Nodes, n)
+		} else {
+			nodes = append(nodes, n)
+		}
 
-			// func signature
-			sign := node.Func.Signature
-			if node.Func.Parent() != nil {
-				sign = node.Func.Parent().Signature
-			}
+		nodeMap[key] = n
+		return n
+	}
 
-			// omit type from label
-			if groupType && sign.Recv() != nil {
-				parts := strings.Split(label, ".")
-				label = parts[len(parts)-1]
+	// recursive functions, cycles are collapsed into single nodes if requested
+	inCycle := make(map[*funcNode]*callCycle)
+	cycleNodes := make(map[*callCycle]*funcNode)
+	for _, c := range overlays.cycles {
+		for _, f := range c.Funcs {
+			inCycle[f] = c
+		}
+		if !overlays.collapse || len(c.Funcs) == 1 {
+			continue
+		}
+		n := &funcNode{
+			ID:      fmt.Sprintf("cycle:%d", c.ID),
+			Name:    fmt.Sprintf("cycle of %d functions", len(c.Funcs)),
+			Pkg:     c.Funcs[0].Pkg,
+			PkgName: c.Funcs[0].PkgName,
+		}
+		for _, f := range c.Funcs {
+			if f.Pkg != n.Pkg {
+				n.Pkg, n.PkgName = "", ""
 			}
-
-			pkgPath := node.Func.Pkg.Pkg.Path()
-			isStdPkg := isStdPkgPath(pkgPath)
-
-			// set node color
-			if isFocused {
-				attrs["fillcolor"] = "lightblue"
-			} else if isStdPkg {
-				attrs["fillcolor"] = "#adedad"
-			} else {
-				attrs["fillcolor"] = "moccasin"
+		}
+		cycleNodes[c] = n
+	}
+	var sprintCycleNode = func(node *funcNode) *dotNode {
+		c, ok := inCycle[node]
+		if !ok {
+			return sprintNode(node)
+		}
+		n := sprintNode(node)
+		if cn, ok := cycleNodes[c]; ok {
+			n = sprintNode(cn)
+			var names []string
+			for _, f := range c.Funcs {
+				names = append(names, f.ShortName())
 			}
+			n.Attrs["label"] = cn.Name
+			n.Attrs["shape"] = "box3d"
+			n.Attrs["fillcolor"] = "mistyrose"
+			delete(n.Attrs, "URL")
+			n.Attrs["tooltip"] = fmt.Sprintf("cycle %d:\n%s", c.ID, strings.Join(names, "\n"))
+		}
+		n.Attrs["color"] = "red"
+		n.Attrs["penwidth"] = "2.5"
+		return n
+	}
 
-			// include pkg name
-			if !groupPkg && !isFocused {
-				label = fmt.Sprintf("%s\n%s", node.Func.Pkg.Pkg.Name(), label)
-			}
+	count := 0
+	for _, edge := range cg.Edges {
+		count++
 
-			attrs["label"] = label
+		caller := edge.caller
+		callee := edge.callee
 
-			// func styles
-			if node.Func.Parent() != nil {
-				attrs["style"] = "dotted,filled"
-			} else if node.Func.Object() != nil && node.Func.Object().Exported() {
-				attrs["penwidth"] = "1.5"
-			} else {
-				attrs["penwidth"] = "0.5"
-			}
+		posCaller := caller.Pos
+		posEdge := edge.Pos
+		//fileCaller := fmt.Sprintf("%s:%d", posCaller.Filename, posCaller.Line)
+		filenameCaller := filepath.Base(posCaller.Filename)
 
-			c := cluster
+		//logf(" - %s -> %s (%s -> %s) %v\n", caller.Pkg, callee.Pkg, caller, callee, filenameCaller)
 
-			// group by pkg
-			if groupPkg && !isFocused {
-				label := node.Func.Pkg.Pkg.Name()
-				if isStdPkg {
-					label = node.Func.Pkg.Pkg.Path()
-				}
-				key := node.Func.Pkg.Pkg.Path()
-				if _, ok := c.Clusters[key]; !ok {
-					c.Clusters[key] = &dotCluster{
-						ID:       key,
-						Clusters: make(map[string]*dotCluster),
-						Attrs: dotAttrs{
-							"penwidth":  "0.8",
-							"fontsize":  "16",
-							"label":     label,
-							"style":     "filled",
-							"fillcolor": "lightyellow",
-							"URL":       fmt.Sprintf("/?f=%s", key),
-							"fontname":  "Tahoma bold",
-							"tooltip":   fmt.Sprintf("package: %s", key),
-							"rank":      "sink",
-						},
-					}
-					if isStdPkg {
-						c.Clusters[key].Attrs["fillcolor"] = "#E0FFE1"
-					}
-				}
-				c = c.Clusters[key]
-			}
+		callerPkg := caller.Pkg
+		calleePkg := callee.Pkg
 
-			// group by type
-			if groupType && sign.Recv() != nil {
-				label := strings.Split(node.Func.RelString(node.Func.Pkg.Pkg), ".")[0]
-				key := sign.Recv().Type().String()
-				if _, ok := c.Clusters[key]; !ok {
-					c.Clusters[key] = &dotCluster{
-						ID:       key,
-						Clusters: make(map[string]*dotCluster),
-						Attrs: dotAttrs{
-							"penwidth":  "0.5",
-							"fontsize":  "15",
-							"fontcolor": "#222222",
-							"label":     label,
-							"labelloc":  "b",
-							"style":     "rounded,filled",
-							"fillcolor": "wheat2",
-							"tooltip":   fmt.Sprintf("type: %s", key),
-						},
-					}
-					if isFocused {
-						c.Clusters[key].Attrs["fillcolor"] = "lightsteelblue"
-					} else if isStdPkg {
-						c.Clusters[key].Attrs["fillcolor"] = "#c2e3c2"
-					}
-				}
-				c = c.Clusters[key]
-			}
+		// focus specific funcs
+		if focusEdges != nil && !focusEdges[edge] {
+			continue
+		}
 
-			attrs["tooltip"] = nodeTooltip
+		// focus specific pkg
+		if focusPkg != nil &&
+			!isFocused(edge) {
+			continue
+		}
 
-			n := &dotNode{
-				ID:    node.Func.String(),
-				Attrs: attrs,
-			}
+		if filter.omit(edge) {
+			continue
+		}
 
-			if c != nil {
-				c.Nodes = append(c.Nodes, n)
-			} else {
-				nodes = append(nodes, n)
-			}
+		//var buf bytes.Buffer
+		//data, _ := json.MarshalIndent(caller.Func, "", " ")
+		//logf("call node: %s -> %s\n %v", caller, callee, string(data))
+		logf("call node: %s -> %s (%s -> %s) %v\n", caller.Pkg, callee.Pkg, caller, callee, filenameCaller)
 
-			nodeMap[key] = n
-			return n
+		// calls inside collapsed cycle
+		if c, ok := inCycle[caller]; ok && c == inCycle[callee] && cycleNodes[c] != nil {
+			continue
 		}
-		callerNode := sprintNode(edge.Caller, true)
-		calleeNode := sprintNode(edge.Callee, false)
+
+		callerNode := sprintCycleNode(caller)
+		calleeNode := sprintCycleNode(callee)
 
 		// edges
 		attrs := make(dotAttrs)
+		kind := edge.Kind
 
+		switch kind {
 		// dynamic call
-		if edge.Site != nil && edge.Site.Common().StaticCallee() == nil {
+		case callKindDynamic:
 			attrs["style"] = "dashed"
-		}
-
 		// go & defer calls
-		switch edge.Site.(type) {
-		case *ssa.Go:
+		case callKindGo:
 			attrs["arrowhead"] = "normalnoneodot"
-		case *ssa.Defer:
+		case callKindDefer:
 			attrs["arrowhead"] = "normalnoneodiamond"
+		case callKindObserved:
+			attrs["style"] = "dotted"
+			attrs["color"] = "darkviolet"
 		}
 
 		// colorize calls outside focused pkg
 		if focusPkg != nil &&
-			(calleePkg.Path() != focusPkg.Path() || callerPkg.Path() != focusPkg.Path()) {
+			(calleePkg != focusPkg.Path || callerPkg != focusPkg.Path) {
 			attrs["color"] = "saddlebrown"
 		}
 
+		// highlight recursive calls
+		if c, ok := inCycle[caller]; ok && c == inCycle[callee] {
+			attrs["color"] = "red"
+			attrs["penwidth"] = "2.0"
+		}
+
+		// calls never executed by tests
+		if overlays.cover != nil && overlays.cover.notExecuted(edge) {
+			attrs["style"] = "dashed"
+			attrs["color"] = "#a0a0a0"
+		}
+
+		// calls leading to changed functions
+		if overlays.impact != nil && overlays.impact.Calls[edge] {
+			attrs["color"] = "#e67e00"
+			attrs["penwidth"] = "2.0"
+		}
+
+		// runtime profile overlay, width shows cost of calls
+		var callCost string
+		if overlays.costs != nil {
+			if v := overlays.costs.Call(edge); v > 0 {
+				attrs["penwidth"] = fmt.Sprintf("%.1f", 1+5*float64(v)/float64(overlays.costs.maxCall))
+				callCost = fmt.Sprintf(" (%s %s)", overlays.costs.Profile.Format(v), overlays.costs.Profile.Type)
+			}
+		}
+
 		// use position in file where callee is called as tooltip for the edge
 		fileEdge := fmt.Sprintf(
 			"at %s:%d: calling [%s]",
 			filepath.Base(posEdge.Filename),
 			posEdge.Line,
-			edge.Callee.Func.String(),
-		)
+			callee.ID,
+		) + callCost
+		if kind == callKindObserved {
+			fileEdge += " observed at runtime"
+		}
+
+		// changes between revisions
+		if overlays.diff != nil {
+			switch {
+			case overlays.diff.AddedCalls[edge]:
+				attrs["color"] = "#2ca02c"
+				attrs["penwidth"] = "2.0"
+				fileEdge = fmt.Sprintf("%s (added)", fileEdge)
+			case overlays.diff.RemovedCalls[edge]:
+				attrs["color"] = "#d62728"
+				attrs["penwidth"] = "2.0"
+				fileEdge = fmt.Sprintf("%s (removed)", fileEdge)
+			default:
+				attrs["color"] = "#d0d0d0"
+			}
+		}
+
+		// calls denied by rules
+		if r, ok := overlays.violations[edge]; ok {
+			attrs["color"] = "red"
+			attrs["penwidth"] = "3.0"
+			attrs["style"] = "bold"
+			fileEdge = fmt.Sprintf("%s (violates %s at %s)", fileEdge, r, r.Pos)
+		}
 
 		// omit duplicate calls, except for tooltip enhancements
-		key := fmt.Sprintf("%s = %s => %s", caller.Func, edge.Description(), callee.Func)
+		key := fmt.Sprintf("%s = %s => %s", callerNode, kind, calleeNode)
 		if _, ok := edgeMap[key]; !ok {
 			attrs["tooltip"] = fileEdge
 			e := &dotEdge{
 				From:  callerNode,
 				To:    calleeNode,
 				Attrs: attrs,
+				Kind:  kind,
+				Sites: []token.Position{posEdge},
 			}
 			edgeMap[key] = e
 		} else {
+			edgeMap[key].Sites = append(edgeMap[key].Sites, posEdge)
 			// make sure, tooltip is created correctly
 			if _, okk := edgeMap[key].Attrs["tooltip"]; !okk {
 				edgeMap[key].Attrs["tooltip"] = fileEdge
@@ -416,11 +740,6 @@ func printOutput(
 				)
 			}
 		}
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 
 	// get edges form edgeMap
@@ -433,15 +752,56 @@ func printOutput(
 		edges = append(edges, e)
 	}
 
-	logf("%d/%d nodes", len(nodeMap), len(cg.Nodes))
+	// unreachable functions of shown packages
+	if overlays.dead != nil {
+		shown := make(map[string]bool)
+		for _, n := range nodeMap {
+			shown[n.Pkg] = true
+		}
+		for _, f := range overlays.dead {
+			if focusPkg != nil && f.Pkg != focusPkg.Path && !shown[f.Pkg] ||
+				focusFuncs != nil && !shown[f.Pkg] {
+				continue
+			}
+			if len(filter.limitPaths) > 0 && !hasPrefix(f, filter.limitPaths) ||
+				hasPrefix(f, filter.ignorePaths) {
+				continue
+			}
+			n := sprintNode(f)
+			n.Dead = true
+			n.Attrs["style"] = "dashed,filled"
+			n.Attrs["fillcolor"] = "#e0e0e0"
+			n.Attrs["fontcolor"] = "#808080"
+			n.Attrs["color"] = "#a0a0a0"
+			n.Attrs["tooltip"] = fmt.Sprintf("%s\nunreachable from entry points", n.Attrs["tooltip"])
+		}
+	}
+
+	logf("%d/%d nodes", len(nodeMap), len(cg.Funcs))
 	logf("%d/%d edges", len(edges), count)
 
-	title := ""
-	if mainPkg != nil && mainPkg.Pkg != nil {
-		title = mainPkg.Pkg.Path()
+	title := mainPkg
+	if title == "" {
+		title = strings.Join(cg.Mains, ", ")
+	}
+
+	// allow switching between main packages used as root
+	var mains []dotMain
+	if len(cg.Mains) > 1 {
+		for _, m := range cg.Mains {
+			mains = append(mains, dotMain{
+				Path:     m,
+				URL:      fmt.Sprintf("/?main=%s&f=%s", url.QueryEscape(m), url.QueryEscape(m)),
+				Selected: m == mainPkg,
+			})
+		}
 	}
 	dot := &dotGraph{
 		Title:   title,
+		Errors:  errs,
+		Mains:   mains,
+		Dead:    overlays.dead != nil,
+		DeadURL: overlays.deadURL,
 		Minlen:  minlen,
 		Cluster: cluster,
 		Nodes:   nodes,
@@ -456,7 +816,7 @@ func printOutput(
 	}
 
 	var buf bytes.Buffer
-	if err := dot.WriteDot(&buf); err != nil {
+	if err := write(dot, &buf); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsInter(t *testing.T) {
+	tests := []struct {
+		callee funcNode
+		want   bool
+	}{
+		{funcNode{Name: "Exported", Exported: true, HasObject: true}, false},
+		{funcNode{Name: "unexported", HasObject: true}, true},
+		// closures and wrappers have no types object
+		{funcNode{Name: "main$1", Closure: true}, false},
+		{funcNode{Name: "(*T).unexported"}, false},
+	}
+	for _, tt := range tests {
+		edge := &callEdge{caller: &funcNode{Name: "main"}, callee: &tt.callee}
+		if got := isInter(edge); got != tt.want {
+			t.Errorf("isInter(main -> %s) = %v, want %v", tt.callee.Name, got, tt.want)
+		}
+	}
+}
+
+func TestNeighborEdges(t *testing.T) {
+	g := testCallGraph(
+		"a.Y -> a.X", "a.X -> a.A",
+		"a.A -> a.B", "a.B -> a.C", "a.C -> a.D",
+		"a.A -> a.A", "a.C -> a.A",
+	)
+	tests := []struct {
+		name                     string
+		callerDepth, calleeDepth int
+		want                     []string
+	}{
+		{"depth 0", 0, 0, nil},
+		{"depth 1", 1, 1, []string{"a.A -> a.A", "a.A -> a.B", "a.C -> a.A", "a.X -> a.A"}},
+		{"callees only", 0, 3, []string{"a.A -> a.A", "a.A -> a.B", "a.B -> a.C", "a.C -> a.A", "a.C -> a.D"}},
+		{"depth N", 5, 5, []string{
+			"a.A -> a.A", "a.A -> a.B", "a.B -> a.C", "a.C -> a.A", "a.C -> a.D",
+			"a.X -> a.A", "a.Y -> a.X",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edges := neighborEdges(testFunc(t, g, "a.A"), tt.callerDepth, tt.calleeDepth, &edgeFilter{})
+			var got []string
+			for e := range edges {
+				got = append(got, e.caller.PkgName+"."+e.caller.Name+" -> "+e.callee.PkgName+"."+e.callee.Name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("neighborEdges(a.A, %d, %d) = %q, want %q", tt.callerDepth, tt.calleeDepth, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// callPath is a sequence of calls leading from one function to another,
+// each hop is represented by one of possibly several calls between the pair.
+type callPath []*callEdge
+
+// findPaths returns at most max simple paths from one function to another,
+// shorter paths come first and paths longer than maxLen calls are omitted,
+// zero maxLen means no limit. Calls excluded by the filter are not followed.
+// Paths are found by Yen's algorithm, which runs a breadth-first search for
+// each function of the previous path, so the search takes polynomial time
+// even in large graphs with exponentially many paths.
+func findPaths(from, to *funcNode, max, maxLen int, filter *edgeFilter) []callPath {
+	if from == to || max <= 0 {
+		return nil
+	}
+
+	type hop struct{ caller, callee *funcNode }
+	// shortest returns the shortest path from src to the target
+	// avoiding banned functions and removed hops
+	shortest := func(src *funcNode, banned map[*funcNode]bool, removed map[hop]bool) callPath {
+		prev := map[*funcNode]*callEdge{src: nil}
+		queue := []*funcNode{src}
+		for len(queue) > 0 {
+			f := queue[0]
+			queue = queue[1:]
+			if f == to {
+				var path callPath
+				for e := prev[to]; e != nil; e = prev[e.caller] {
+					path = append(path, e)
+				}
+				slices.Reverse(path)
+				return path
+			}
+			for _, e := range f.out {
+				callee := e.callee
+				if _, ok := prev[callee]; ok || banned[callee] || removed[hop{f, callee}] || filter.omit(e) {
+					continue
+				}
+				prev[callee] = e
+				queue = append(queue, callee)
+			}
+		}
+		return nil
+	}
+	// same reports whether the paths go through the same functions
+	same := func(a, b callPath) bool {
+		return slices.EqualFunc(a, b, func(x, y *callEdge) bool {
+			return x.caller == y.caller && x.callee == y.callee
+		})
+	}
+
+	first := shortest(from, nil, nil)
+	if first == nil || maxLen > 0 && len(first) > maxLen {
+		return nil
+	}
+	paths := []callPath{first}
+	var candidates []callPath
+	for len(paths) < max {
+		// deviate from the last path at each of its functions
+		last := paths[len(paths)-1]
+		for i := range last {
+			root := last[:i]
+			removed := make(map[hop]bool)
+			for _, p := range paths {
+				if len(p) > i && same(p[:i], root) {
+					removed[hop{p[i].caller, p[i].callee}] = true
+				}
+			}
+			banned := make(map[*funcNode]bool)
+			for _, e := range root {
+				banned[e.caller] = true
+			}
+			spur := shortest(last[i].caller, banned, removed)
+			if spur == nil {
+				continue
+			}
+			path := append(append(callPath(nil), root...), spur...)
+			known := func(p callPath) bool { return same(p, path) }
+			if !slices.ContainsFunc(candidates, known) && !slices.ContainsFunc(paths, known) {
+				candidates = append(candidates, path)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+
+		// the first found of the shortest candidates
+		best := 0
+		for i, p := range candidates {
+			if len(p) < len(candidates[best]) {
+				best = i
+			}
+		}
+		if maxLen > 0 && len(candidates[best]) > maxLen {
+			break
+		}
+		paths = append(paths, candidates[best])
+		candidates = slices.Delete(candidates, best, best+1)
+	}
+
+	return paths
+}
+
+// pathEdges returns all calls along the paths, including
+// other calls between the same functions to show every call site.
+func pathEdges(paths []callPath, filter *edgeFilter) map[*callEdge]bool {
+	edges := make(map[*callEdge]bool)
+	for _, path := range paths {
+		for _, hop := range path {
+			for _, e := range hop.caller.out {
+				if e.callee == hop.callee && !filter.omit(e) {
+					edges[e] = true
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// uniqueCalls returns the first of calls between each two functions,
+// so a call is listed once together with all its call sites.
+func uniqueCalls(edges []*callEdge) []*callEdge {
+	type call struct{ caller, callee *funcNode }
+	seen := make(map[call]bool)
+	var unique []*callEdge
+	for _, e := range edges {
+		if c := (call{e.caller, e.callee}); !seen[c] {
+			seen[c] = true
+			unique = append(unique, e)
+		}
+	}
+	return unique
+}
+
+// callSites returns positions of all calls between the functions of the hop.
+func callSites(hop *callEdge, filter *edgeFilter) string {
+	var sites []string
+	for _, e := range hop.caller.out {
+		if e.callee == hop.callee && !filter.omit(e) {
+			site := fmt.Sprintf("%s:%d", filepath.Base(e.Pos.Filename), e.Pos.Line)
+			if !slices.Contains(sites, site) {
+				sites = append(sites, site)
+			}
+		}
+	}
+	return strings.Join(sites, ", ")
+}
+
+// printPaths writes paths as text for terminals.
+func printPaths(paths []callPath, filter *edgeFilter) []byte {
+	var buf bytes.Buffer
+	for i, path := range paths {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "path %d/%d (%d calls):\n", i+1, len(paths), len(path))
+		fmt.Fprintf(&buf, "  %s\n", path[0].caller.ShortName())
+		for _, hop := range path {
+			fmt.Fprintf(&buf, "  -> %s  at %s\n", hop.callee.ShortName(), callSites(hop, filter))
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func pathString(p callPath) string {
+	names := []string{p[0].caller.PkgName + "." + p[0].caller.Name}
+	for _, e := range p {
+		names = append(names, e.callee.PkgName+"."+e.callee.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestFindPaths(t *testing.T) {
+	diamond := []string{
+		"a.A -> a.B", "a.A -> a.C", "a.B -> a.D", "a.C -> a.D",
+		"a.A -> b.X", "b.X -> b.Y", "b.Y -> a.D",
+	}
+	tests := []struct {
+		name     string
+		calls    []string
+		from, to string
+		max      int
+		maxLen   int
+		filter   edgeFilter
+		want     []string
+	}{
+		{
+			name:  "shortest first",
+			calls: diamond,
+			from:  "a.A", to: "a.D", max: 5,
+			want: []string{"a.A a.B a.D", "a.A a.C a.D", "a.A b.X b.Y a.D"},
+		},
+		{
+			name:  "max",
+			calls: diamond,
+			from:  "a.A", to: "a.D", max: 2,
+			want: []string{"a.A a.B a.D", "a.A a.C a.D"},
+		},
+		{
+			name:  "max length",
+			calls: diamond,
+			from:  "a.A", to: "a.D", max: 5, maxLen: 2,
+			want: []string{"a.A a.B a.D", "a.A a.C a.D"},
+		},
+		{
+			name:  "filtered",
+			calls: diamond,
+			from:  "a.A", to: "a.D", max: 5,
+			filter: edgeFilter{ignorePaths: []string{"example.com/b"}},
+			want:   []string{"a.A a.B a.D", "a.A a.C a.D"},
+		},
+		{
+			name:  "cycles",
+			calls: []string{"a.A -> a.B", "a.B -> a.A", "a.B -> a.B", "a.B -> a.C", "a.A -> a.C", "a.C -> a.B"},
+			from:  "a.A", to: "a.C", max: 5,
+			want: []string{"a.A a.C", "a.A a.B a.C"},
+		},
+		{
+			name:  "repeated calls",
+			calls: []string{"a.A -> a.B", "a.A -> a.B", "a.B -> a.C", "a.B -> a.C"},
+			from:  "a.A", to: "a.C", max: 5,
+			want: []string{"a.A a.B a.C"},
+		},
+		{
+			name:  "unreachable",
+			calls: []string{"a.A -> a.B", "a.C -> a.A"},
+			from:  "a.A", to: "a.C", max: 5,
+		},
+		{
+			name:  "same function",
+			calls: []string{"a.A -> a.A"},
+			from:  "a.A", to: "a.A", max: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testCallGraph(tt.calls...)
+			paths := findPaths(testFunc(t, g, tt.from), testFunc(t, g, tt.to), tt.max, tt.maxLen, &tt.filter)
+			var got []string
+			for _, p := range paths {
+				got = append(got, pathString(p))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("paths:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestFindPathsLarge checks that the search does not enumerate
+// exponentially many paths of a graph.
+func TestFindPathsLarge(t *testing.T) {
+	calls := []string{"a.Start -> a.L0", "a.Start -> a.R0"}
+	for i := 1; i < 60; i++ {
+		for _, caller := range []string{"a.L", "a.R"} {
+			calls = append(calls,
+				fmt.Sprintf("%s%d -> a.L%d", caller, i-1, i),
+				fmt.Sprintf("%s%d -> a.R%d", caller, i-1, i))
+		}
+	}
+	calls = append(calls, "a.L59 -> a.End", "a.R59 -> a.End", "a.Start -> a.Other")
+	g := testCallGraph(calls...)
+
+	paths := findPaths(testFunc(t, g, "a.Start"), testFunc(t, g, "a.End"), 10, 0, &edgeFilter{})
+	if len(paths) != 10 {
+		t.Errorf("found %d paths, want 10", len(paths))
+	}
+	paths = findPaths(testFunc(t, g, "a.Start"), testFunc(t, g, "a.Other"), 10, 0, &edgeFilter{})
+	if len(paths) != 1 {
+		t.Errorf("found %d paths, want 1", len(paths))
+	}
+}
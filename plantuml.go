@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func plantumlLabel(s string) string {
+	s = strings.ReplaceAll(s, `"`, "'")
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func plantumlColor(c string) string {
+	if c == "" || strings.HasPrefix(c, "#") {
+		return c
+	}
+	return "#" + c
+}
+
+func (g *dotGraph) WritePlantUML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "@startuml")
+	if g.Title != "" {
+		fmt.Fprintf(bw, "title %s\n", g.Title)
+	}
+	switch g.Options["rankdir"] {
+	case "", "LR", "RL":
+		fmt.Fprintln(bw, "left to right direction")
+	}
+
+	ids := make(map[*dotNode]string)
+
+	writeNode := func(n *dotNode, indent string) {
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[n] = id
+		fmt.Fprintf(bw, "%srectangle \"%s\" as %s %s\n", indent, plantumlLabel(n.Attrs["label"]), id, plantumlColor(n.Attrs["fillcolor"]))
+	}
+
+	var writeCluster func(c *dotCluster, indent string)
+	writeCluster = func(c *dotCluster, indent string) {
+		inner := indent
+		// root cluster is drawn only when it represents focused package
+		if label := c.Attrs["label"]; c != g.Cluster || label != "" {
+			fmt.Fprintf(bw, "%spackage \"%s\" {\n", indent, plantumlLabel(label))
+			inner = indent + "  "
+		}
+		for _, n := range c.sortedNodes() {
+			writeNode(n, inner)
+		}
+		for _, sub := range c.sortedClusters() {
+			writeCluster(sub, inner)
+		}
+		if inner != indent {
+			fmt.Fprintf(bw, "%s}\n", indent)
+		}
+	}
+	if g.Cluster != nil {
+		writeCluster(g.Cluster, "")
+	}
+	for _, n := range g.Nodes {
+		writeNode(n, "")
+	}
+
+	for _, e := range g.sortedEdges() {
+		arrow := "-->"
+		// dynamic calls
+		if e.Kind == callKindDynamic {
+			arrow = "..>"
+		}
+		line := fmt.Sprintf("%s %s %s", ids[e.From], arrow, ids[e.To])
+		// go & defer calls
+		if e.Kind == callKindGo || e.Kind == callKindDefer {
+			line += fmt.Sprintf(" : %s", e.Kind)
+		}
+		fmt.Fprintln(bw, line)
+	}
+
+	fmt.Fprintln(bw, "@enduml")
+	return bw.Flush()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWritePlantUML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testDotGraph().WritePlantUML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"start", "@startuml\n"},
+		{"title", "title example.com/cmd\n"},
+		{"direction", "left to right direction\n"},
+		{"package", `package "pkg" {`},
+		{"node", `rectangle "pkg\nRun" as n1 #moccasin`},
+		{"dashed static call", "n0 --> n1\n"},
+		{"bold dynamic call", "n1 ..> n2\n"},
+		{"go call", "n0 --> n2 : go\n"},
+		{"end", "@enduml\n"},
+	} {
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("%s: output does not contain %q:\n%s", tt.name, tt.want, out)
+		}
+	}
+}
+
+func TestPlantumlColor(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"moccasin", "#moccasin"},
+		{"#adedad", "#adedad"},
+	} {
+		if got := plantumlColor(tt.in); got != tt.want {
+			t.Errorf("plantumlColor(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
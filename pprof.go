@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// ==[ type def/func: pprofProfile ]=============================================
+
+// pprofProfile holds stacks sampled by pprof CPU or heap profile,
+// only the default sample type (e.g. cpu or inuse_space) is used.
+type pprofProfile struct {
+	Path string
+	Sum  string // hash of the profile, used in cache keys
+	Type string // sample type
+	Unit string
+
+	samples []pprofSample
+}
+
+type pprofSample struct {
+	Stack []pprofFrame // leaf first, inlined calls are separate frames
+	Value int64
+}
+
+type pprofFrame struct {
+	Func string // symbol name, e.g. example.com/pkg.(*T).Method
+	File string
+	Line int
+}
+
+// loadPprofProfile reads profile written by runtime/pprof.
+func loadPprofProfile(file string) (*pprofProfile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	prof, err := profile.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if len(prof.SampleType) == 0 {
+		return nil, fmt.Errorf("%s: no sample types", file)
+	}
+
+	// the last sample type is used, unless default is set
+	value := len(prof.SampleType) - 1
+	for i, st := range prof.SampleType {
+		if st.Type == prof.DefaultSampleType {
+			value = i
+		}
+	}
+	p := &pprofProfile{
+		Path: file,
+		Sum:  hex.EncodeToString(sum[:]),
+		Type: prof.SampleType[value].Type,
+		Unit: prof.SampleType[value].Unit,
+	}
+	for _, s := range prof.Sample {
+		if value >= len(s.Value) || s.Value[value] == 0 {
+			continue
+		}
+		ps := pprofSample{Value: s.Value[value]}
+		for _, loc := range s.Location {
+			for _, l := range loc.Line {
+				if l.Function == nil {
+					continue
+				}
+				ps.Stack = append(ps.Stack, pprofFrame{
+					Func: l.Function.Name,
+					File: l.Function.Filename,
+					Line: int(l.Line),
+				})
+			}
+		}
+		p.samples = append(p.samples, ps)
+	}
+
+	logf("loaded %d samples of %s from %s", len(p.samples), p.Type, file)
+
+	return p, nil
+}
+
+// Format returns value formatted using unit of the profile.
+func (p *pprofProfile) Format(v int64) string {
+	switch p.Unit {
+	case "nanoseconds":
+		return time.Duration(v).String()
+	case "bytes":
+		units := []string{"B", "kB", "MB", "GB", "TB"}
+		f, i := float64(v), 0
+		for ; f >= 1024 && i < len(units)-1; i++ {
+			f /= 1024
+		}
+		return strconv.FormatFloat(f, 'f', 1, 64) + units[i]
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// runtimeName returns symbol name of the function used in profiles,
+// e.g. (*example.com/pkg.T).M$1 is example.com/pkg.(*T).M.func1.
+func runtimeName(f *funcNode) string {
+	pkg := symbolPath(f.Pkg)
+	if f.PkgName == "main" {
+		pkg = "main"
+	}
+	name := f.Name
+
+	// value receivers are not parenthesized
+	if rest, ok := strings.CutPrefix(name, "("); ok && !strings.HasPrefix(rest, "*") {
+		name = strings.Replace(rest, ")", "", 1)
+	}
+	// numbered init functions start from zero
+	if n, ok := strings.CutPrefix(name, "init#"); ok {
+		if i, err := strconv.Atoi(n); err == nil {
+			name = fmt.Sprintf("init.%d", i-1)
+		}
+	}
+	// closures, nested ones are numbered without func prefix
+	if parent, closures, ok := strings.Cut(name, "$"); ok {
+		name = parent
+		for i, n := range strings.Split(closures, "$") {
+			if i == 0 {
+				n = "func" + n
+			}
+			name += "." + n
+		}
+	}
+	// type arguments of instances are omitted
+	var b strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '[':
+			if depth == 0 {
+				b.WriteString("[...]")
+			}
+			depth++
+		case r == ']':
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return pkg + "." + b.String()
+}
+
+// symbolPath returns package path as used in symbol names, the linker escapes
+// dots in its last element and special characters, e.g. gopkg.in/yaml%2ev3.
+func symbolPath(path string) string {
+	slash := strings.LastIndex(path, "/")
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c <= ' ' || c == '.' && i > slash || c == '%' || c == '"' || c >= 0x7f {
+			fmt.Fprintf(&b, "%%%02x", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// ==[ type def/func: profileCosts ]=============================================
+
+// profileCosts are costs of functions and calls of the call graph,
+// keyed by function IDs, so they can be used with derived graphs.
+type profileCosts struct {
+	Profile *pprofProfile
+	Total   int64
+
+	flat, cum map[string]int64
+	calls     map[[2]string]int64
+
+	maxFlat, maxCum, maxCall int64
+
+	// calls sampled at runtime, which are missing in the call graph
+	// (e.g. reflection or dynamic calls not resolved by the algorithm)
+	observed []*callEdge
+}
+
+// costs maps samples of the profile to functions of the call graph by their
+// symbol names. Frames of functions missing in the graph (e.g. wrappers
+// generated by the compiler) are skipped, so calls through them are kept.
+func (p *pprofProfile) costs(cg *callGraph) *profileCosts {
+	c := &profileCosts{
+		Profile: p,
+		flat:    make(map[string]int64),
+		cum:     make(map[string]int64),
+		calls:   make(map[[2]string]int64),
+	}
+
+	byName := make(map[string][]*funcNode)
+	index := make(map[*funcNode]int)
+	for i, f := range cg.Funcs {
+		n := runtimeName(f)
+		byName[n] = append(byName[n], f)
+		index[f] = i
+	}
+	static := make(map[[2]string]bool)
+	for _, e := range cg.Edges {
+		static[[2]string{e.caller.ID, e.callee.ID}] = true
+	}
+	observed := make(map[[2]string]*callEdge)
+
+	for _, s := range p.samples {
+		c.Total += s.Value
+		if len(s.Stack) > 0 {
+			for _, f := range byName[s.Stack[0].Func] {
+				c.flat[f.ID] += s.Value
+			}
+		}
+
+		var stack []pprofFrame
+		for _, fr := range s.Stack {
+			if len(byName[fr.Func]) > 0 {
+				stack = append(stack, fr)
+			}
+		}
+		// recursive functions and calls are counted once per sample
+		seen := make(map[string]bool)
+		seenCalls := make(map[[2]string]bool)
+		for i, fr := range stack {
+			for _, f := range byName[fr.Func] {
+				if !seen[f.ID] {
+					seen[f.ID] = true
+					c.cum[f.ID] += s.Value
+				}
+			}
+			if i == len(stack)-1 {
+				continue
+			}
+			caller, callee := stack[i+1], fr
+			found := false
+			for _, cf := range byName[caller.Func] {
+				for _, f := range byName[callee.Func] {
+					call := [2]string{cf.ID, f.ID}
+					if static[call] {
+						found = true
+						if !seenCalls[call] {
+							seenCalls[call] = true
+							c.calls[call] += s.Value
+						}
+					}
+				}
+			}
+			if found {
+				continue
+			}
+			cf, f := byName[caller.Func][0], byName[callee.Func][0]
+			call := [2]string{cf.ID, f.ID}
+			if _, ok := observed[call]; !ok {
+				e := &callEdge{
+					Caller: index[cf],
+					Callee: index[f],
+					Kind:   callKindObserved,
+					Pos:    token.Position{Filename: caller.File, Line: caller.Line},
+				}
+				observed[call] = e
+				c.observed = append(c.observed, e)
+			}
+			if !seenCalls[call] {
+				seenCalls[call] = true
+				c.calls[call] += s.Value
+			}
+		}
+	}
+
+	for _, v := range c.flat {
+		c.maxFlat = max(c.maxFlat, v)
+	}
+	for _, v := range c.cum {
+		c.maxCum = max(c.maxCum, v)
+	}
+	for _, v := range c.calls {
+		c.maxCall = max(c.maxCall, v)
+	}
+
+	logf("profile mapped to %d functions, %d calls observed only at runtime", len(c.cum), len(c.observed))
+
+	return c
+}
+
+// Func returns flat and cumulative cost of the function.
+func (c *profileCosts) Func(f *funcNode) (flat, cum int64) {
+	return c.flat[f.ID], c.cum[f.ID]
+}
+
+// Call returns cost of calls from one function to another.
+func (c *profileCosts) Call(e *callEdge) int64 {
+	return c.calls[[2]string{e.caller.ID, e.callee.ID}]
+}
+
+// percent returns value as percentage of the profile total.
+func (c *profileCosts) percent(v int64) float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return 100 * float64(v) / float64(c.Total)
+}
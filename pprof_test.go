@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestRuntimeName(t *testing.T) {
+	tests := []struct {
+		f    funcNode
+		want string
+	}{
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "Run"}, "example.com/pkg.Run"},
+		{funcNode{Pkg: "example.com/cmd/app", PkgName: "main", Name: "main"}, "main.main"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "(T).Run"}, "example.com/pkg.T.Run"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "(*T).Run"}, "example.com/pkg.(*T).Run"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "Run$1"}, "example.com/pkg.Run.func1"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "Run$1$2"}, "example.com/pkg.Run.func1.2"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "(*T).Run$2"}, "example.com/pkg.(*T).Run.func2"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "init#1"}, "example.com/pkg.init.0"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "Map[int, string]"}, "example.com/pkg.Map[...]"},
+		{funcNode{Pkg: "example.com/pkg", PkgName: "pkg", Name: "(*List[[]int]).Push"}, "example.com/pkg.(*List[...]).Push"},
+		{funcNode{Pkg: "gopkg.in/yaml.v3", PkgName: "yaml", Name: "Unmarshal"}, "gopkg.in/yaml%2ev3.Unmarshal"},
+		{funcNode{Pkg: "example.com/a.b/c.d.e", PkgName: "c", Name: "Run"}, "example.com/a.b/c%2ed%2ee.Run"},
+		{funcNode{Pkg: "example.com/pkg_test", PkgName: "pkg_test", Name: "TestRun"}, "example.com/pkg_test.TestRun"},
+	}
+	for _, tt := range tests {
+		if got := runtimeName(&tt.f); got != tt.want {
+			t.Errorf("runtimeName(%s.%s) = %q, want %q", tt.f.Pkg, tt.f.Name, got, tt.want)
+		}
+	}
+}
+
+var profileSink [][]byte
+
+//go:noinline
+func allocForProfile() {
+	for i := 0; i < 100; i++ {
+		profileSink = append(profileSink, make([]byte, 4096))
+	}
+}
+
+// TestLoadPprofProfile maps heap profile written by runtime/pprof
+// to the function allocating memory.
+func TestLoadPprofProfile(t *testing.T) {
+	rate := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	defer func() { runtime.MemProfileRate = rate }()
+
+	allocForProfile()
+	runtime.GC()
+
+	file := filepath.Join(t.TempDir(), "heap.pprof")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pprof.Lookup("heap").WriteTo(f, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := loadPprofProfile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "inuse_space" || p.Unit != "bytes" {
+		t.Errorf("sample type = %s %s, want inuse_space bytes", p.Type, p.Unit)
+	}
+
+	// package main of the test binary is named by its import path
+	cg := &callGraph{Funcs: []*funcNode{{
+		ID:      "github.com/ofabry/go-callvis.allocForProfile",
+		Name:    "allocForProfile",
+		Pkg:     "github.com/ofabry/go-callvis",
+		PkgName: "callvis",
+	}}}
+	cg.link()
+	flat, cum := p.costs(cg).Func(cg.Funcs[0])
+	if flat < 100*4096 || cum < flat {
+		t.Errorf("costs of allocating function: flat %d, cum %d, want at least %d", flat, cum, 100*4096)
+	}
+}
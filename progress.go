@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ==[ type def/func: progress   ]===============================================
+
+// progress reports phase of the running analysis,
+// it is shown by the server until the first analysis is done.
+type progress struct {
+	mu     sync.Mutex
+	start  time.Time
+	phase  string
+	detail string
+	errors []string
+	done   bool
+	failed bool
+}
+
+var analysisProgress = &progress{start: time.Now()}
+
+// Phase sets current phase of the analysis with optional details,
+// nil progress is not reported.
+func (p *progress) Phase(phase string, format string, a ...interface{}) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.phase = phase
+	p.detail = fmt.Sprintf(format, a...)
+}
+
+// Errors sets errors reported for loaded packages.
+func (p *progress) Errors(errs []string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.errors = errs
+}
+
+// Finish marks the analysis as done, err is set if it failed.
+func (p *progress) Finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done = true
+	if err != nil {
+		p.failed = true
+		p.phase = "analysis failed"
+		p.detail = err.Error()
+	}
+}
+
+func (p *progress) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	status := struct {
+		Phase   string   `json:"phase"`
+		Detail  string   `json:"detail"`
+		Errors  []string `json:"errors"`
+		Done    bool     `json:"done"`
+		Failed  bool     `json:"failed"`
+		Elapsed float64  `json:"elapsed"`
+	}{
+		Phase:   p.phase,
+		Detail:  p.detail,
+		Errors:  p.errors,
+		Done:    p.done,
+		Failed:  p.failed,
+		Elapsed: time.Since(p.start).Round(time.Millisecond).Seconds(),
+	}
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(status)
+}
+
+// packageErrors returns errors of all loaded packages.
+func packageErrors(initial []*packages.Package) []string {
+	var errs []string
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		for _, err := range p.Errors {
+			errs = append(errs, err.Error())
+		}
+	})
+	return errs
+}
+
+// serveProgress serves page showing progress of the analysis,
+// the page is reloaded to show the graph once the analysis is done.
+func serveProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, progressPage)
+}
+
+const progressPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>go-callvis</title>
+<style>
+body { font-family: Tahoma, sans-serif; margin: 3em; color: #222; }
+#phase { font-size: 1.4em; }
+#detail { color: #666; margin-top: .5em; }
+#errors { color: #b00; white-space: pre-wrap; margin-top: 1em; }
+</style>
+</head>
+<body>
+<div id="phase">starting analysis</div>
+<div id="detail"></div>
+<div id="errors"></div>
+<script>
+function poll() {
+	fetch("/progress").then(function(r) { return r.json(); }).then(function(p) {
+		if (p.done && !p.failed) {
+			location.reload();
+			return;
+		}
+		document.getElementById("phase").textContent = p.phase + (p.failed ? "" : "…");
+		document.getElementById("detail").textContent = p.detail + " (" + p.elapsed.toFixed(1) + "s)";
+		document.getElementById("errors").textContent = (p.errors || []).join("\n");
+		if (!p.failed) {
+			setTimeout(poll, 500);
+		}
+	}).catch(function() { setTimeout(poll, 1000); });
+}
+poll();
+</script>
+</body>
+</html>
+`
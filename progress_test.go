@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestProgressOfRequests(t *testing.T) {
+	dir := testModule(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+	p := &progress{}
+	a := &analysis{progress: p}
+	if err := a.DoAnalysis(CallGraphTypeStatic, dir, false, false, false, "", []string{"."}); err != nil {
+		t.Fatal(err)
+	}
+	p.Finish(nil)
+	phase, detail := p.phase, p.detail
+	if phase == "" {
+		t.Fatal("phases of the analysis were not reported")
+	}
+
+	// graphs requested after the analysis, even invalid ones, keep the progress
+	for _, algo := range []CallGraphType{CallGraphTypeCha, "bogus"} {
+		a.CallGraph(algo, "")
+		if p.phase != phase || p.detail != detail {
+			t.Errorf("progress changed by %s graph: %s (%s), want %s (%s)", algo, p.phase, p.detail, phase, detail)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ==[ type def/func: archRule   ]===============================================
+
+// archRule allows or denies calls from packages matching one pattern
+// to packages matching another, see loadRules for the syntax.
+type archRule struct {
+	Allow bool
+	From  string
+	To    string
+	Pos   string // position of the rule in rules file
+}
+
+func (r *archRule) String() string {
+	action := "deny"
+	if r.Allow {
+		action = "allow"
+	}
+	return fmt.Sprintf("%s %s -> %s", action, r.From, r.To)
+}
+
+// loadRules reads rules file, each line contains single rule:
+//
+//	deny internal/domain/... -> internal/transport/...
+//	deny !pkg/db/... -> database/sql
+//	allow cmd/... -> ...
+//
+// The first rule matching a call decides if it is allowed,
+// calls not matching any rule are allowed. Empty lines
+// and lines starting with # are ignored.
+func loadRules(path string) ([]*archRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*archRule
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 4 || fields[2] != "->" || fields[0] != "allow" && fields[0] != "deny" {
+			return nil, fmt.Errorf("%s:%d: invalid rule, expected 'allow|deny <from> -> <to>': %s", path, line, text)
+		}
+		rules = append(rules, &archRule{
+			Allow: fields[0] == "allow",
+			From:  fields[1],
+			To:    fields[3],
+			Pos:   fmt.Sprintf("%s:%d", path, line),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	logf("loaded %d rules from %s", len(rules), path)
+
+	return rules, nil
+}
+
+// matchPackage reports whether import path matches the pattern. Pattern matches
+// the whole path or its trailing elements, so that paths inside the module can
+// be written without module path. Suffix /... matches sub-packages as well,
+// ... alone matches everything and ! prefix negates the pattern.
+func matchPackage(pattern, path string) bool {
+	if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+		return !matchPackage(negated, path)
+	}
+	if pattern == "..." {
+		return true
+	}
+	prefix, wildcard := strings.CutSuffix(pattern, "/...")
+	for p := path; ; {
+		if p == prefix || wildcard && strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+		i := strings.Index(p, "/")
+		if i < 0 {
+			return false
+		}
+		p = p[i+1:]
+	}
+}
+
+// checkRules returns calls denied by the rules, together with the rule.
+// Calls inside a package are never checked.
+func checkRules(cg *callGraph, filter *edgeFilter, rules []*archRule) map[*callEdge]*archRule {
+	violations := make(map[*callEdge]*archRule)
+	for _, edge := range cg.Edges {
+		caller, callee := edge.caller.Pkg, edge.callee.Pkg
+		if caller == callee || filter.omit(edge) {
+			continue
+		}
+		for _, r := range rules {
+			if matchPackage(r.From, caller) && matchPackage(r.To, callee) {
+				if !r.Allow {
+					violations[edge] = r
+				}
+				break
+			}
+		}
+	}
+
+	logf("found %d calls violating rules", len(violations))
+
+	return violations
+}
+
+// printViolations writes calls denied by the rules grouped by the rule.
+func printViolations(rules []*archRule, violations map[*callEdge]*archRule, filter *edgeFilter) []byte {
+	byRule := make(map[*archRule][]*callEdge)
+	for e, r := range violations {
+		byRule[r] = append(byRule[r], e)
+	}
+
+	var buf bytes.Buffer
+	for _, r := range rules {
+		edges := byRule[r]
+		if len(edges) == 0 {
+			continue
+		}
+		sort.Slice(edges, func(i, j int) bool {
+			if edges[i].caller.ID != edges[j].caller.ID {
+				return edges[i].caller.ID < edges[j].caller.ID
+			}
+			return edges[i].callee.ID < edges[j].callee.ID
+		})
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%s: %s\n", r.Pos, r)
+		// single call of each function, all its call sites are listed
+		for _, e := range uniqueCalls(edges) {
+			fmt.Fprintf(&buf, "  %s -> %s  at %s\n", e.caller.ShortName(), e.callee.ShortName(), callSites(e, filter))
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchPackage(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"example.com/app/internal/db", "example.com/app/internal/db", true},
+		{"internal/db", "example.com/app/internal/db", true},
+		{"db", "example.com/app/internal/db", true},
+		{"nal/db", "example.com/app/internal/db", false},
+		{"internal", "example.com/app/internal/db", false},
+		{"internal/...", "example.com/app/internal/db", true},
+		{"internal/...", "example.com/app/internal", true},
+		{"internal/...", "example.com/app/internaldb", false},
+		{"app/...", "example.com/app/internal/db", true},
+		{"...", "example.com/app", true},
+		{"...", "fmt", true},
+		{"fmt", "fmt", true},
+		{"fmt", "example.com/fmtx", false},
+		{"!internal/...", "example.com/app/internal/db", false},
+		{"!internal/...", "example.com/app/cmd", true},
+		{"!db", "example.com/app/internal/db", false},
+		{"!...", "fmt", false},
+	}
+	for _, tt := range tests {
+		if got := matchPackage(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPackage(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules")
+	rules := `# layers
+allow app/cmd -> app/internal/...
+allow app/internal/... -> app/internal/...
+deny ... -> app/internal/...
+deny app/internal/... -> !app/internal/...
+`
+	if err := os.WriteFile(file, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := loadRules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := testCallGraph(
+		"cmd.Main -> internal/db.Open",
+		"api.Serve -> internal/db.Open",
+		"api.Serve -> internal/db.Open",
+		"internal/db.Open -> internal/db.open",
+		"internal/db.Open -> internal/log.Print",
+		"internal/db.Open -> api.Hook",
+	)
+	for _, f := range g.Funcs {
+		f.Pkg = "example.com/app/" + f.Pkg[len("example.com/"):]
+	}
+	violations := checkRules(g, &edgeFilter{}, r)
+
+	var got []string
+	for _, e := range uniqueCalls(g.Edges) {
+		if rule, ok := violations[e]; ok {
+			got = append(got, e.caller.PkgName+"."+e.caller.Name+" -> "+e.callee.PkgName+"."+e.callee.Name+": "+rule.Pos)
+		}
+	}
+	want := []string{
+		"api.Serve -> internal/db.Open: " + file + ":4",
+		"internal/db.Open -> api.Hook: " + file + ":5",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("violations:\n%q\nwant:\n%q", got, want)
+	}
+}
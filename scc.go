@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// callCycle is a strongly connected component of the call graph, its functions
+// call each other recursively. It has single function if it calls itself.
+type callCycle struct {
+	ID    int
+	Funcs []*funcNode
+	Calls []*callEdge // calls between functions of the cycle
+}
+
+// findCycles returns recursive functions and groups of mutually recursive
+// functions found using Tarjan's algorithm, calls omitted by the filter are
+// not considered. Larger cycles come first.
+func findCycles(cg *callGraph, filter *edgeFilter) []*callCycle {
+	var (
+		index   = make(map[*funcNode]int)
+		lowlink = make(map[*funcNode]int)
+		onStack = make(map[*funcNode]bool)
+		stack   []*funcNode
+		comps   [][]*funcNode
+	)
+	var connect func(f *funcNode)
+	connect = func(f *funcNode) {
+		index[f] = len(index)
+		lowlink[f] = index[f]
+		stack = append(stack, f)
+		onStack[f] = true
+
+		for _, e := range f.out {
+			if filter.omit(e) {
+				continue
+			}
+			if _, ok := index[e.callee]; !ok {
+				connect(e.callee)
+				lowlink[f] = min(lowlink[f], lowlink[e.callee])
+			} else if onStack[e.callee] {
+				lowlink[f] = min(lowlink[f], index[e.callee])
+			}
+		}
+
+		if lowlink[f] == index[f] {
+			var comp []*funcNode
+			for {
+				n := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[n] = false
+				comp = append(comp, n)
+				if n == f {
+					break
+				}
+			}
+			comps = append(comps, comp)
+		}
+	}
+	for _, f := range cg.Funcs {
+		if _, ok := index[f]; !ok {
+			connect(f)
+		}
+	}
+
+	var cycles []*callCycle
+	for _, comp := range comps {
+		sort.Slice(comp, func(i, j int) bool {
+			return comp[i].ID < comp[j].ID
+		})
+		members := make(map[*funcNode]bool)
+		for _, f := range comp {
+			members[f] = true
+		}
+		var calls []*callEdge
+		for _, f := range comp {
+			for _, e := range f.out {
+				if members[e.callee] && !filter.omit(e) {
+					calls = append(calls, e)
+				}
+			}
+		}
+		// single function is a cycle only if it calls itself
+		if len(calls) == 0 {
+			continue
+		}
+		cycles = append(cycles, &callCycle{
+			Funcs: comp,
+			Calls: calls,
+		})
+	}
+	sort.SliceStable(cycles, func(i, j int) bool {
+		if len(cycles[i].Funcs) != len(cycles[j].Funcs) {
+			return len(cycles[i].Funcs) > len(cycles[j].Funcs)
+		}
+		return cycles[i].Funcs[0].ID < cycles[j].Funcs[0].ID
+	})
+	for i, c := range cycles {
+		c.ID = i + 1
+	}
+
+	logf("found %d cycles", len(cycles))
+
+	return cycles
+}
+
+// printCycles writes cycles with calls forming them as text.
+func printCycles(cycles []*callCycle, filter *edgeFilter) []byte {
+	var buf bytes.Buffer
+	for i, c := range cycles {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "cycle %d (%d functions):\n", c.ID, len(c.Funcs))
+		// single call of each function, all its call sites are listed
+		for _, e := range uniqueCalls(c.Calls) {
+			fmt.Fprintf(&buf, "  %s -> %s  at %s\n", e.caller.ShortName(), e.callee.ShortName(), callSites(e, filter))
+		}
+	}
+	return buf.Bytes()
+}
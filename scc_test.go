@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestFindCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		calls  []string
+		filter edgeFilter
+		want   []string // functions of cycles, calls count in parentheses
+	}{
+		{
+			name:  "acyclic",
+			calls: []string{"a.A -> a.B", "a.B -> a.C", "a.A -> a.C"},
+		},
+		{
+			name:  "recursive function",
+			calls: []string{"a.A -> a.B", "a.B -> a.B"},
+			want:  []string{"a.B (1)"},
+		},
+		{
+			name: "larger cycles first",
+			calls: []string{
+				"a.A -> a.B", "a.B -> a.A",
+				"a.C -> a.D", "a.D -> a.E", "a.E -> a.C", "a.E -> a.D",
+				"a.A -> a.C", "a.F -> a.F",
+			},
+			want: []string{"a.C a.D a.E (4)", "a.A a.B (2)", "a.F (1)"},
+		},
+		{
+			name:  "repeated calls",
+			calls: []string{"a.A -> a.B", "a.B -> a.A", "a.B -> a.A"},
+			want:  []string{"a.A a.B (3)"},
+		},
+		{
+			name:   "filtered",
+			calls:  []string{"a.A -> b.B", "b.B -> a.A", "a.A -> a.C", "a.C -> a.A"},
+			filter: edgeFilter{ignorePaths: []string{"example.com/b"}},
+			want:   []string{"a.A a.C (2)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testCallGraph(tt.calls...)
+			var got []string
+			for i, c := range findCycles(g, &tt.filter) {
+				if c.ID != i+1 {
+					t.Errorf("cycle %d has ID %d", i+1, c.ID)
+				}
+				var names []string
+				for _, f := range c.Funcs {
+					names = append(names, f.PkgName+"."+f.Name)
+				}
+				got = append(got, fmt.Sprintf("%s (%d)", strings.Join(names, " "), len(c.Calls)))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("cycles: %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintCycles(t *testing.T) {
+	g := testCallGraph("a.A -> a.B", "a.B -> a.A", "a.B -> a.A")
+	for i, e := range g.Edges {
+		e.Pos = token.Position{Filename: "/src/a/a.go", Line: 10 + i}
+	}
+	got := string(printCycles(findCycles(g, &edgeFilter{}), &edgeFilter{}))
+	want := `cycle 1 (2 functions):
+  a.A -> a.B  at a.go:10
+  a.B -> a.A  at a.go:11, a.go:12
+`
+	if got != want {
+		t.Errorf("printCycles() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// snapshotVersion must be increased when callGraph changes,
+// so that incompatible snapshots are not loaded.
+const snapshotVersion = 7
+
+// sourcesFingerprint returns hash of go.mod and go.sum files, build flags
+// and contents of source files of all packages, including dependencies.
+// Packages are only listed, which is much faster than loading them.
+func sourcesFingerprint(cfg *packages.Config, args []string) (string, error) {
+	listCfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+			packages.NeedImports | packages.NeedDeps | packages.NeedModule,
+		Tests:      cfg.Tests,
+		Dir:        cfg.Dir,
+		BuildFlags: cfg.BuildFlags,
+	}
+	initial, err := packages.Load(listCfg, args...)
+	if err != nil {
+		return "", err
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		for _, f := range p.CompiledGoFiles {
+			add(f)
+		}
+		if p.Module != nil && p.Module.GoMod != "" {
+			add(p.Module.GoMod)
+			add(filepath.Join(filepath.Dir(p.Module.GoMod), "go.sum"))
+		}
+	})
+	sort.Strings(files)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "args=%q tests=%v flags=%q\n", args, cfg.Tests, cfg.BuildFlags)
+	for _, f := range files {
+		fmt.Fprintf(h, "%s\n", f)
+		if err := hashFile(h, f); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	logf("fingerprint of %d files computed", len(files))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// snapshotPath returns path of the call graph snapshot in the cache directory.
+func (a *analysis) snapshotPath(key graphKey) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "version=%d algo=%s root=%s lib=%v allowErrors=%v sources=%s\n",
+		snapshotVersion, key.algo, key.root, a.lib, a.allowErrors, a.fingerprint)
+	return filepath.Join(a.cacheDir, "snapshots", hex.EncodeToString(h.Sum(nil))+".gob")
+}
+
+func loadSnapshot(path string) (*callGraph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var graph callGraph
+	if err := gob.NewDecoder(f).Decode(&graph); err != nil {
+		return nil, err
+	}
+	graph.link()
+
+	return &graph, nil
+}
+
+// saveSnapshot writes the graph to temporary file first,
+// so that concurrent runs never see incomplete snapshot.
+func saveSnapshot(path string, graph *callGraph) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "snapshot_*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := gob.NewEncoder(f).Encode(graph); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
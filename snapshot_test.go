@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const snapshotMain = `package main
+
+func main() { helper() }
+
+func helper() {}
+`
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := testModule(t, map[string]string{"main.go": snapshotMain})
+	a := new(analysis)
+	if err := a.DoAnalysis(CallGraphTypeStatic, dir, false, false, false, "", []string{"."}); err != nil {
+		t.Fatal(err)
+	}
+	graph, err := a.CallGraph(CallGraphTypeStatic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshots", "graph.gob")
+	if err := saveSnapshot(path, graph); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, graph) {
+		t.Errorf("loaded snapshot differs:\n%+v\nwant:\n%+v", loaded, graph)
+	}
+}
+
+func TestSnapshotSourcesChanged(t *testing.T) {
+	dir := testModule(t, map[string]string{"main.go": snapshotMain})
+	cacheDir := t.TempDir()
+	analyze := func() *analysis {
+		t.Helper()
+		a := new(analysis)
+		if err := a.DoAnalysis(CallGraphTypeStatic, dir, false, false, false, cacheDir, []string{"."}); err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	first := analyze()
+	if first.prog == nil {
+		t.Fatal("first analysis did not load the program")
+	}
+	// program is not loaded when the graph is found in the snapshot
+	if cached := analyze(); cached.prog != nil || cached.fingerprint != first.fingerprint {
+		t.Fatal("snapshot of unchanged sources was not used")
+	}
+
+	changed := snapshotMain + "\nfunc added() {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(changed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := analyze()
+	if a.fingerprint == first.fingerprint {
+		t.Error("fingerprint of changed sources is the same")
+	}
+	if a.prog == nil {
+		t.Error("snapshot of previous sources was used")
+	}
+	graph, err := a.CallGraph(CallGraphTypeStatic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range graph.Funcs {
+		found = found || f.Name == "added"
+	}
+	if !found {
+		t.Error("function added to sources is missing in the graph")
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// ==[ type def/func: graphStats ]===============================================
+
+// graphStats holds metrics of functions and packages in the filtered call graph,
+// multiple calls between the same functions are counted once.
+type graphStats struct {
+	Funcs    []*funcStats
+	Packages []*pkgStats
+
+	byFunc map[*funcNode]*funcStats
+	byPkg  map[string]*pkgStats
+
+	// maximal values used for heat colors
+	maxFunc     map[string]float64
+	maxCoupling int
+}
+
+type funcStats struct {
+	Func        *funcNode
+	FanIn       int     // number of distinct callers
+	FanOut      int     // number of distinct callees
+	Betweenness float64 // number of shortest paths going through the function
+	Reach       int     // number of functions reachable from the function
+}
+
+type pkgStats struct {
+	Path        string  `json:"package"`
+	Afferent    int     `json:"afferent"`    // number of other packages calling into the package
+	Efferent    int     `json:"efferent"`    // number of other packages the package calls into
+	Instability float64 `json:"instability"` // efferent / (afferent + efferent)
+}
+
+const (
+	statFanIn       = "fanin"
+	statFanOut      = "fanout"
+	statBetweenness = "betweenness"
+	statReach       = "reach"
+	statAfferent    = "afferent"
+	statEfferent    = "efferent"
+	statInstability = "instability"
+)
+
+// funcStatValue returns value of the function metric, ok is false for unknown metric.
+func funcStatValue(s *funcStats, metric string) (v float64, ok bool) {
+	switch metric {
+	case statFanIn:
+		return float64(s.FanIn), true
+	case statFanOut:
+		return float64(s.FanOut), true
+	case statBetweenness:
+		return s.Betweenness, true
+	case statReach:
+		return float64(s.Reach), true
+	}
+	return 0, false
+}
+
+// pkgStatValue returns value of the package metric, ok is false for unknown metric.
+func pkgStatValue(s *pkgStats, metric string) (v float64, ok bool) {
+	switch metric {
+	case statAfferent:
+		return float64(s.Afferent), true
+	case statEfferent:
+		return float64(s.Efferent), true
+	case statInstability:
+		return s.Instability, true
+	}
+	return 0, false
+}
+
+// isSortKey reports whether stats tables can be sorted by the key.
+func isSortKey(key string) bool {
+	_, funcMetric := funcStatValue(&funcStats{}, key)
+	_, pkgMetric := pkgStatValue(&pkgStats{}, key)
+	return funcMetric || pkgMetric || key == "name"
+}
+
+// computeStats computes metrics of functions and packages in the call graph,
+// calls omitted by the filter are not considered.
+func computeStats(cg *callGraph, filter *edgeFilter) *graphStats {
+	st := &graphStats{
+		byFunc: make(map[*funcNode]*funcStats),
+		byPkg:  make(map[string]*pkgStats),
+
+		maxFunc: make(map[string]float64),
+	}
+
+	// distinct calls between functions of the filtered graph
+	index := make(map[*funcNode]int)
+	var out [][]int
+	add := func(f *funcNode) int {
+		i, ok := index[f]
+		if !ok {
+			i = len(st.Funcs)
+			index[f] = i
+			st.Funcs = append(st.Funcs, &funcStats{Func: f})
+			out = append(out, nil)
+		}
+		return i
+	}
+	type call struct{ caller, callee int }
+	calls := make(map[call]bool)
+	afferent := make(map[string]map[string]bool)
+	efferent := make(map[string]map[string]bool)
+	for _, edge := range cg.Edges {
+		if filter.omit(edge) {
+			continue
+		}
+		c := call{add(edge.caller), add(edge.callee)}
+		if c.caller == c.callee || calls[c] {
+			continue
+		}
+		calls[c] = true
+		out[c.caller] = append(out[c.caller], c.callee)
+		st.Funcs[c.caller].FanOut++
+		st.Funcs[c.callee].FanIn++
+
+		if callerPkg, calleePkg := edge.caller.Pkg, edge.callee.Pkg; callerPkg != calleePkg {
+			if efferent[callerPkg] == nil {
+				efferent[callerPkg] = make(map[string]bool)
+			}
+			efferent[callerPkg][calleePkg] = true
+			if afferent[calleePkg] == nil {
+				afferent[calleePkg] = make(map[string]bool)
+			}
+			afferent[calleePkg][callerPkg] = true
+		}
+	}
+
+	// betweenness using Brandes' algorithm, BFS from every
+	// function gives number of reachable functions as well
+	n := len(st.Funcs)
+	var (
+		stack = make([]int, 0, n)
+		queue = make([]int, 0, n)
+		pred  = make([][]int, n)
+		sigma = make([]float64, n)
+		dist  = make([]int, n)
+		delta = make([]float64, n)
+	)
+	for s := 0; s < n; s++ {
+		for i := 0; i < n; i++ {
+			pred[i] = pred[i][:0]
+			sigma[i] = 0
+			dist[i] = -1
+			delta[i] = 0
+		}
+		sigma[s] = 1
+		dist[s] = 0
+		stack = stack[:0]
+		queue = append(queue[:0], s)
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range out[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					pred[w] = append(pred[w], v)
+				}
+			}
+		}
+		st.Funcs[s].Reach = len(stack) - 1
+		for i := len(stack) - 1; i >= 0; i-- {
+			w := stack[i]
+			for _, v := range pred[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				st.Funcs[w].Betweenness += delta[w]
+			}
+		}
+	}
+
+	for _, f := range st.Funcs {
+		st.byFunc[f.Func] = f
+		for _, m := range []string{statFanIn, statFanOut, statBetweenness, statReach} {
+			v, _ := funcStatValue(f, m)
+			st.maxFunc[m] = math.Max(st.maxFunc[m], v)
+		}
+		if _, ok := st.byPkg[f.Func.Pkg]; ok {
+			continue
+		}
+		p := &pkgStats{
+			Path:     f.Func.Pkg,
+			Afferent: len(afferent[f.Func.Pkg]),
+			Efferent: len(efferent[f.Func.Pkg]),
+		}
+		if p.Afferent+p.Efferent > 0 {
+			p.Instability = float64(p.Efferent) / float64(p.Afferent+p.Efferent)
+		}
+		st.byPkg[p.Path] = p
+		st.Packages = append(st.Packages, p)
+		if c := p.Afferent + p.Efferent; c > st.maxCoupling {
+			st.maxCoupling = c
+		}
+	}
+
+	logf("stats of %d functions and %d packages computed", len(st.Funcs), len(st.Packages))
+
+	return st
+}
+
+// Sort sorts functions and packages by given metric in descending order,
+// the other table is sorted by fan-in or afferent coupling respectively.
+// Metric "name" sorts both tables by name.
+func (st *graphStats) Sort(metric string) {
+	funcMetric, pkgMetric := statFanIn, statAfferent
+	if _, ok := funcStatValue(&funcStats{}, metric); ok || metric == "name" {
+		funcMetric = metric
+	}
+	if _, ok := pkgStatValue(&pkgStats{}, metric); ok || metric == "name" {
+		pkgMetric = metric
+	}
+
+	sort.SliceStable(st.Funcs, func(i, j int) bool {
+		a, b := st.Funcs[i], st.Funcs[j]
+		va, _ := funcStatValue(a, funcMetric)
+		vb, _ := funcStatValue(b, funcMetric)
+		if va != vb {
+			return va > vb
+		}
+		return a.Func.ID < b.Func.ID
+	})
+	sort.SliceStable(st.Packages, func(i, j int) bool {
+		a, b := st.Packages[i], st.Packages[j]
+		va, _ := pkgStatValue(a, pkgMetric)
+		vb, _ := pkgStatValue(b, pkgMetric)
+		if va != vb {
+			return va > vb
+		}
+		return a.Path < b.Path
+	})
+}
+
+// heat returns value of the function metric relative to its maximum.
+func (st *graphStats) heat(f *funcNode, metric string) float64 {
+	s, ok := st.byFunc[f]
+	if !ok || st.maxFunc[metric] == 0 {
+		return 0
+	}
+	v, _ := funcStatValue(s, metric)
+	return v / st.maxFunc[metric]
+}
+
+// pkgHeat returns coupling (afferent + efferent) of the package relative to its maximum.
+func (st *graphStats) pkgHeat(path string) float64 {
+	p, ok := st.byPkg[path]
+	if !ok || st.maxCoupling == 0 {
+		return 0
+	}
+	return float64(p.Afferent+p.Efferent) / float64(st.maxCoupling)
+}
+
+// heatColor returns color for value between 0 and 1,
+// going from light yellow over orange to red.
+func heatColor(v float64) string {
+	return gradientColor(v, [][3]float64{
+		{0xff, 0xff, 0xcc},
+		{0xfd, 0x8d, 0x3c},
+		{0xe3, 0x1a, 0x1c},
+	})
+}
+
+// gradientColor returns color for value between 0 and 1
+// interpolated between evenly spaced RGB stops.
+func gradientColor(v float64, stops [][3]float64) string {
+	v = math.Max(0, math.Min(1, v)) * float64(len(stops)-1)
+	i := int(v)
+	if i == len(stops)-1 {
+		i--
+	}
+	t := v - float64(i)
+	var c [3]int
+	for k := range c {
+		c[k] = int(math.Round(stops[i][k] + (stops[i+1][k]-stops[i][k])*t))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+}
+
+// WriteText writes tables of functions and packages for terminals.
+func (st *graphStats) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FUNCTION\tFAN-IN\tFAN-OUT\tBETWEENNESS\tREACH")
+	for _, f := range st.Funcs {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%d\n", f.Func.ShortName(), f.FanIn, f.FanOut, f.Betweenness, f.Reach)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "PACKAGE\tAFFERENT\tEFFERENT\tINSTABILITY")
+	for _, p := range st.Packages {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\n", p.Path, p.Afferent, p.Efferent, p.Instability)
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes functions and packages as rows of single table,
+// columns not relevant for the row are left empty.
+func (st *graphStats) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "name", "fan_in", "fan_out", "betweenness", "reach", "afferent", "efferent", "instability"})
+	for _, f := range st.Funcs {
+		cw.Write([]string{
+			"func",
+			f.Func.ID,
+			strconv.Itoa(f.FanIn),
+			strconv.Itoa(f.FanOut),
+			strconv.FormatFloat(f.Betweenness, 'f', -1, 64),
+			strconv.Itoa(f.Reach),
+			"", "", "",
+		})
+	}
+	for _, p := range st.Packages {
+		cw.Write([]string{
+			"package",
+			p.Path,
+			"", "", "", "",
+			strconv.Itoa(p.Afferent),
+			strconv.Itoa(p.Efferent),
+			strconv.FormatFloat(p.Instability, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonStats struct {
+	Funcs    []jsonFuncStats `json:"functions"`
+	Packages []*pkgStats     `json:"packages"`
+}
+
+type jsonFuncStats struct {
+	ID          string  `json:"id"`
+	Package     string  `json:"package"`
+	FanIn       int     `json:"fanIn"`
+	FanOut      int     `json:"fanOut"`
+	Betweenness float64 `json:"betweenness"`
+	Reach       int     `json:"reach"`
+}
+
+func (st *graphStats) WriteJSON(w io.Writer) error {
+	out := jsonStats{
+		Funcs:    []jsonFuncStats{},
+		Packages: st.Packages,
+	}
+	for _, f := range st.Funcs {
+		out.Funcs = append(out.Funcs, jsonFuncStats{
+			ID:          f.Func.ID,
+			Package:     f.Func.Pkg,
+			FanIn:       f.FanIn,
+			FanOut:      f.FanOut,
+			Betweenness: f.Betweenness,
+			Reach:       f.Reach,
+		})
+	}
+	if out.Packages == nil {
+		out.Packages = []*pkgStats{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestComputeStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		calls  []string
+		filter edgeFilter
+		funcs  []string // function, fan-in, fan-out, betweenness, reach
+		pkgs   []string // package, afferent, efferent, instability
+	}{
+		{
+			name:  "diamond",
+			calls: []string{"a.A -> a.B", "a.A -> a.C", "a.B -> a.D", "a.C -> a.D"},
+			funcs: []string{"a.A 0 2 0.0 3", "a.B 1 1 0.5 1", "a.C 1 1 0.5 1", "a.D 2 0 0.0 0"},
+			pkgs:  []string{"a 0 0 0.00"},
+		},
+		{
+			name: "chain across packages",
+			calls: []string{
+				"a.A -> b.B", "a.A -> b.B", "b.B -> b.C", "b.C -> b.C", "b.C -> c.D",
+			},
+			funcs: []string{"a.A 0 1 0.0 3", "b.B 1 1 2.0 2", "b.C 1 1 2.0 1", "c.D 1 0 0.0 0"},
+			pkgs:  []string{"a 0 1 1.00", "b 1 1 0.50", "c 1 0 0.00"},
+		},
+		{
+			name:  "cycle",
+			calls: []string{"a.A -> a.B", "a.B -> a.C", "a.C -> a.A"},
+			funcs: []string{"a.A 1 1 1.0 2", "a.B 1 1 1.0 2", "a.C 1 1 1.0 2"},
+			pkgs:  []string{"a 0 0 0.00"},
+		},
+		{
+			name:   "filtered",
+			calls:  []string{"a.A -> a.B", "a.B -> b.C", "a.A -> b.C"},
+			filter: edgeFilter{ignorePaths: []string{"example.com/b"}},
+			funcs:  []string{"a.A 0 1 0.0 1", "a.B 1 0 0.0 0"},
+			pkgs:   []string{"a 0 0 0.00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := computeStats(testCallGraph(tt.calls...), &tt.filter)
+			st.Sort("name")
+
+			var funcs, pkgs []string
+			for _, f := range st.Funcs {
+				funcs = append(funcs, fmt.Sprintf("%s.%s %d %d %.1f %d",
+					f.Func.PkgName, f.Func.Name, f.FanIn, f.FanOut, f.Betweenness, f.Reach))
+			}
+			for _, p := range st.Packages {
+				pkgs = append(pkgs, fmt.Sprintf("%s %d %d %.2f",
+					strings.TrimPrefix(p.Path, "example.com/"), p.Afferent, p.Efferent, p.Instability))
+			}
+			if strings.Join(funcs, "\n") != strings.Join(tt.funcs, "\n") {
+				t.Errorf("functions:\n%s\nwant:\n%s", strings.Join(funcs, "\n"), strings.Join(tt.funcs, "\n"))
+			}
+			if strings.Join(pkgs, "\n") != strings.Join(tt.pkgs, "\n") {
+				t.Errorf("packages:\n%s\nwant:\n%s", strings.Join(pkgs, "\n"), strings.Join(tt.pkgs, "\n"))
+			}
+		})
+	}
+}
+
+func TestStatsSortAndHeat(t *testing.T) {
+	st := computeStats(testCallGraph("a.A -> a.C", "a.B -> a.C", "a.C -> a.D"), &edgeFilter{})
+	st.Sort(statFanIn)
+	if got := st.Funcs[0].Func.Name; got != "C" {
+		t.Errorf("function with highest fan-in is %s, want C", got)
+	}
+	c := st.Funcs[0].Func
+	if h := st.heat(c, statFanIn); h != 1 {
+		t.Errorf("heat of C = %v, want 1", h)
+	}
+	if h := st.heat(st.Funcs[len(st.Funcs)-1].Func, statFanIn); h != 0 {
+		t.Errorf("heat of function without callers = %v, want 0", h)
+	}
+}
+
+func TestIsSortKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"name":        true,
+		statFanIn:     true,
+		"instability": true,
+		"afferent":    true,
+		"":            false,
+		"fan-in":      false,
+	} {
+		if got := isSortKey(key); got != want {
+			t.Errorf("isSortKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
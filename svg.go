@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// svgColors maps X11 colors used by Graphviz to colors known to SVG.
+var svgColors = map[string]string{
+	"wheat2": "#eed8ae",
+}
+
+func svgColor(c string, def string) string {
+	if c == "" {
+		return def
+	}
+	if s, ok := svgColors[c]; ok {
+		return s
+	}
+	return c
+}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// svgStroke returns stroke attributes for style and pen width.
+func svgStroke(attrs dotAttrs, style string, color string) string {
+	width := attrFloat(attrs, "penwidth", 1)
+	if strings.Contains(style, "bold") {
+		width = math.Max(width, 2)
+	}
+	s := fmt.Sprintf(`stroke="%s" stroke-width="%.2f"`, svgEscape(color), width)
+	switch {
+	case strings.Contains(style, "dashed"):
+		s += ` stroke-dasharray="5,2"`
+	case strings.Contains(style, "dotted"):
+		s += ` stroke-dasharray="1,5"`
+	}
+	return s
+}
+
+// svgWriter writes elements of SVG image.
+type svgWriter struct {
+	bytes.Buffer
+}
+
+// link starts anchor of the element when it has URL or tooltip,
+// returned function ends it.
+func (w *svgWriter) link(attrs dotAttrs) func() {
+	url, tooltip := attrs["URL"], attrs["tooltip"]
+	if url == "" && tooltip == "" {
+		return func() {}
+	}
+	w.WriteString("<a")
+	if url != "" {
+		fmt.Fprintf(w, ` xlink:href="%s"`, svgEscape(url))
+	}
+	if tooltip != "" {
+		fmt.Fprintf(w, ` xlink:title="%s"`, svgEscape(tooltip))
+	}
+	w.WriteString(">\n")
+	return func() { w.WriteString("</a>\n") }
+}
+
+// text writes lines of text centered vertically at y.
+func (w *svgWriter) text(lines []string, x, y float64, anchor string, font string, fontSize float64, color string) {
+	y -= float64(len(lines)-1) * fontSize * 0.6
+	for _, line := range lines {
+		fmt.Fprintf(w, `<text text-anchor="%s" x="%.2f" y="%.2f" font-family="%s" font-size="%.2f" fill="%s">%s</text>`+"\n",
+			anchor, x, y+fontSize*0.35, font, fontSize, svgEscape(color), svgEscape(line))
+		y += fontSize * 1.2
+	}
+}
+
+func (w *svgWriter) rect(x0, y0, x1, y1 float64, rounded bool, fill string, stroke string) {
+	var rx string
+	if rounded {
+		rx = ` rx="4" ry="4"`
+	}
+	fmt.Fprintf(w, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f"%s fill="%s" %s/>`+"\n",
+		x0, y0, x1-x0, y1-y0, rx, svgEscape(fill), stroke)
+}
+
+func (w *svgWriter) cluster(c *layoutCluster, id *int) {
+	if c.cluster != nil && c.X0 <= c.X1 {
+		*id++
+		attrs := c.cluster.Attrs
+		style := attrs["style"]
+		fill := "none"
+		if strings.Contains(style, "filled") || attrs["bgcolor"] != "" {
+			fill = svgColor(attrs["fillcolor"], svgColor(attrs["bgcolor"], "lightgray"))
+		}
+		pen := svgColor(attrs["pencolor"], svgColor(attrs["color"], "black"))
+		fmt.Fprintf(w, `<g id="clust%d" class="cluster">`+"\n", *id)
+		fmt.Fprintf(w, "<title>%s</title>\n", svgEscape(c.cluster.String()))
+		end := w.link(attrs)
+		w.rect(c.X0, c.Y0, c.X1, c.Y1, strings.Contains(style, "rounded"), fill, svgStroke(attrs, style, pen))
+		if c.label != "" {
+			fontSize := attrFloat(attrs, "fontsize", 14)
+			w.text(strings.Split(c.label, "\n"), (c.X0+c.X1)/2, c.Y0+layoutClusterPad/2+c.labelSize/2,
+				"middle", "Arial", fontSize, svgColor(attrs["fontcolor"], "black"))
+		}
+		end()
+		w.WriteString("</g>\n")
+	}
+	for _, sub := range c.children {
+		w.cluster(sub, id)
+	}
+}
+
+func (w *svgWriter) edge(e *layoutEdge, id int) {
+	attrs := e.edge.Attrs
+	style := attrs["style"]
+	color := svgColor(attrs["color"], "black")
+	fmt.Fprintf(w, `<g id="edge%d" class="edge">`+"\n", id)
+	fmt.Fprintf(w, "<title>%s</title>\n", svgEscape(e.edge.From.ID+"->"+e.edge.To.ID))
+	end := w.link(attrs)
+
+	// line ends at base of the arrow
+	points := append([][2]float64(nil), e.Points...)
+	tip, from := points[len(points)-1], points[len(points)-2]
+	dx, dy := tip[0]-from[0], tip[1]-from[1]
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		dx, dy, length = 0, 1, 1
+	}
+	size := 10 * math.Max(1, math.Sqrt(attrFloat(attrs, "penwidth", 1)))
+	dx, dy = dx/length, dy/length
+	base := [2]float64{tip[0] - dx*size, tip[1] - dy*size}
+	points[len(points)-1] = base
+
+	var d strings.Builder
+	fmt.Fprintf(&d, "M%.2f,%.2f", points[0][0], points[0][1])
+	if len(points) == 4 && e.edge.From == e.edge.To {
+		fmt.Fprintf(&d, " C%.2f,%.2f %.2f,%.2f %.2f,%.2f", points[1][0], points[1][1], points[2][0], points[2][1], points[3][0], points[3][1])
+	} else {
+		for _, p := range points[1:] {
+			fmt.Fprintf(&d, " L%.2f,%.2f", p[0], p[1])
+		}
+	}
+	fmt.Fprintf(w, `<path fill="none" %s d="%s"/>`+"\n", svgStroke(attrs, style, color), d.String())
+	fmt.Fprintf(w, `<polygon fill="%s" stroke="%s" points="%.2f,%.2f %.2f,%.2f %.2f,%.2f"/>`+"\n",
+		svgEscape(color), svgEscape(color),
+		tip[0], tip[1],
+		base[0]-dy*size*0.35, base[1]+dx*size*0.35,
+		base[0]+dy*size*0.35, base[1]-dx*size*0.35)
+	end()
+	w.WriteString("</g>\n")
+}
+
+func (w *svgWriter) node(n *layoutNode, options map[string]string, id int) {
+	attrs := n.node.Attrs
+	style := attrs["style"]
+	if style == "" {
+		style = options["nodestyle"]
+	}
+	shape := attrs["shape"]
+	if shape == "" {
+		shape = options["nodeshape"]
+	}
+	fill := "none"
+	if strings.Contains(style, "filled") {
+		fill = svgColor(attrs["fillcolor"], "honeydew")
+	}
+	stroke := svgStroke(attrs, style, svgColor(attrs["color"], "black"))
+
+	fmt.Fprintf(w, `<g id="node%d" class="node">`+"\n", id)
+	fmt.Fprintf(w, "<title>%s</title>\n", svgEscape(n.node.ID))
+	end := w.link(attrs)
+	switch shape {
+	case "ellipse", "oval", "circle":
+		fmt.Fprintf(w, `<ellipse cx="%.2f" cy="%.2f" rx="%.2f" ry="%.2f" fill="%s" %s/>`+"\n",
+			n.X, n.Y, n.w/2, n.h/2, svgEscape(fill), stroke)
+	default:
+		w.rect(n.X-n.w/2, n.Y-n.h/2, n.X+n.w/2, n.Y+n.h/2, strings.Contains(style, "rounded"), fill, stroke)
+	}
+	w.text(n.label, n.X, n.Y, "middle", "Verdana", n.fontSize, svgColor(attrs["fontcolor"], "black"))
+	end()
+	w.WriteString("</g>\n")
+}
+
+// WriteSVG writes the graph as SVG image using built-in layered layout,
+// so images can be rendered without Graphviz.
+func (g *dotGraph) WriteSVG(w io.Writer) error {
+	l := newGraphLayout(g)
+
+	// main packages and toggle of unreachable functions are placed
+	// in a row below the graph
+	var buttons []*dotNode
+	for i, m := range g.Mains {
+		style, fill := "filled", "white"
+		if m.Selected {
+			style, fill = "filled,bold", "lightblue"
+		}
+		buttons = append(buttons, &dotNode{
+			ID: fmt.Sprintf("main:%d", i),
+			Attrs: dotAttrs{
+				"label":     m.Path,
+				"URL":       m.URL,
+				"tooltip":   "use as root",
+				"style":     style,
+				"fillcolor": fill,
+			},
+		})
+	}
+	if g.DeadURL != "" {
+		label, fill := "show unreachable functions", "white"
+		if g.Dead {
+			label, fill = "hide unreachable functions", "#e0e0e0"
+		}
+		buttons = append(buttons, &dotNode{
+			ID: "toggle:dead",
+			Attrs: dotAttrs{
+				"label":     label,
+				"URL":       g.DeadURL,
+				"tooltip":   "functions unreachable from entry points",
+				"style":     "filled,rounded",
+				"fillcolor": fill,
+			},
+		})
+	}
+	width, height := l.Width, l.Height
+	var row []*layoutNode
+	x := layoutMargin
+	for _, b := range buttons {
+		n := &layoutNode{
+			node:     b,
+			label:    []string{b.Attrs["label"]},
+			fontSize: 14,
+		}
+		tw, th := textSize(n.label, n.fontSize)
+		n.w, n.h = tw+2*11.5, th+12
+		n.X, n.Y = x+n.w/2, height+n.h/2
+		x += n.w + l.nodeSep
+		width = math.Max(width, x+layoutMargin)
+		row = append(row, n)
+	}
+	if len(row) > 0 {
+		height += row[0].h + layoutMargin
+	}
+
+	label := []string{g.Title}
+	if len(g.Errors) > 0 {
+		label = append(label, "", fmt.Sprintf("%d packages contain errors:", len(g.Errors)))
+		label = append(label, g.Errors...)
+	}
+	lw, lh := textSize(label, 14)
+	width = math.Max(width, lw+2*layoutMargin)
+	labelY := height + lh/2
+	height += lh + layoutMargin
+
+	var out svgWriter
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	fmt.Fprintf(&out, `<svg width="%.0fpt" height="%.0fpt" viewBox="0.00 0.00 %.2f %.2f" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">`+"\n",
+		width, height, width, height)
+	out.WriteString(`<g id="graph0" class="graph">` + "\n")
+	out.WriteString("<title>gocallvis</title>\n")
+	out.rect(0, 0, width, height, false, "lightgray", `stroke="none"`)
+
+	id := 0
+	out.cluster(l.root, &id)
+	for i, e := range l.edges {
+		out.edge(e, i+1)
+	}
+	id = 0
+	for _, n := range l.nodes {
+		if n.node != nil {
+			id++
+			out.node(n, g.Options, id)
+		}
+	}
+	for _, n := range row {
+		id++
+		out.node(n, g.Options, id)
+	}
+	out.text(label, layoutMargin, labelY, "start", "Arial", 14, "black")
+
+	out.WriteString("</g>\n</svg>\n")
+	_, err := out.WriteTo(w)
+	return err
+}
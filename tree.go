@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	treeCallees = "callees"
+	treeCallers = "callers"
+)
+
+// printTree writes indented tree of callees or callers of the function as text
+// for terminals. Calls leading back to a function already on the branch are
+// marked as cycles and are not expanded again.
+func printTree(fn *funcNode, direction string, depth int, filter *edgeFilter) []byte {
+	reverse := direction == treeCallers
+	arrow := "->"
+	if reverse {
+		arrow = "<-"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n", fn.ShortName())
+
+	onBranch := map[*funcNode]bool{fn: true}
+	var walk func(f *funcNode, level int)
+	walk = func(f *funcNode, level int) {
+		if level > depth {
+			return
+		}
+		// single call of each function, all its call sites are listed
+		var hops []*callEdge
+		edges := f.out
+		if reverse {
+			edges = f.in
+		}
+		for _, e := range edges {
+			if !filter.omit(e) {
+				hops = append(hops, e)
+			}
+		}
+		hops = uniqueCalls(hops)
+		sort.SliceStable(hops, func(i, j int) bool {
+			pi, pj := hops[i].Pos, hops[j].Pos
+			if pi.Filename != pj.Filename {
+				return pi.Filename < pj.Filename
+			}
+			return pi.Line < pj.Line
+		})
+
+		indent := strings.Repeat("  ", level)
+		for _, hop := range hops {
+			other := hop.callee
+			if reverse {
+				other = hop.caller
+			}
+			fmt.Fprintf(&buf, "%s%s %s  at %s", indent, arrow, other.ShortName(), callSites(hop, filter))
+			if onBranch[other] {
+				buf.WriteString("  (cycle)\n")
+				continue
+			}
+			buf.WriteString("\n")
+			onBranch[other] = true
+			walk(other, level+1)
+			onBranch[other] = false
+		}
+	}
+	walk(fn, 1)
+
+	return buf.Bytes()
+}
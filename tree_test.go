@@ -0,0 +1,45 @@
+package main
+
+import (
+	"go/token"
+	"testing"
+)
+
+func TestPrintTree(t *testing.T) {
+	g := testCallGraph(
+		"a.Main -> a.A", "a.A -> a.B", "a.B -> a.A",
+		"a.Main -> b.C", "a.Main -> a.A",
+	)
+	for i, e := range g.Edges {
+		e.Pos = token.Position{Filename: "/src/a.go", Line: i + 1}
+	}
+
+	tests := []struct {
+		fn        string
+		direction string
+		depth     int
+		want      string
+	}{
+		{"a.Main", treeCallees, 3, `a.Main
+  -> a.A  at a.go:1, a.go:5
+    -> a.B  at a.go:2
+      -> a.A  at a.go:3  (cycle)
+  -> b.C  at a.go:4
+`},
+		{"a.Main", treeCallees, 1, `a.Main
+  -> a.A  at a.go:1, a.go:5
+  -> b.C  at a.go:4
+`},
+		{"a.A", treeCallers, 2, `a.A
+  <- a.Main  at a.go:1, a.go:5
+  <- a.B  at a.go:3
+    <- a.A  at a.go:2  (cycle)
+`},
+	}
+	for _, tt := range tests {
+		got := string(printTree(testFunc(t, g, tt.fn), tt.direction, tt.depth, &edgeFilter{}))
+		if got != tt.want {
+			t.Errorf("printTree(%s, %s, %d):\n%s\nwant:\n%s", tt.fn, tt.direction, tt.depth, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// viewerAssets holds the interactive viewer, which draws the graph
+// fetched in JSON format together with its built-in layout. Assets are
+// embedded, so the viewer works without network access.
+//
+//go:embed viewer
+var viewerAssets embed.FS
+
+// viewerPath is URL path of the viewer, query of the viewer
+// is passed to the server when fetching the graph.
+const viewerPath = "/viewer/"
+
+func viewerHandler() http.Handler {
+	assets, err := fs.Sub(viewerAssets, "viewer")
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix(viewerPath, http.FileServer(http.FS(assets)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != viewerPath {
+			files.ServeHTTP(w, r)
+			return
+		}
+		page, err := fs.ReadFile(assets, "index.html")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(viewerPage(page))
+	})
+}
+
+// viewerPage marks the page in watch mode, the viewer then
+// reloads the graph when notified by the server.
+func viewerPage(page []byte) []byte {
+	if !*watchFlag {
+		return page
+	}
+	return bytes.Replace(page, []byte("<body>"), []byte(`<body data-watch="true">`), 1)
+}
@@ -0,0 +1,576 @@
+// Interactive viewer of go-callvis call graphs. The graph is fetched as JSON
+// together with its layout computed by the server, which is the same layered
+// layout as the built-in SVG renderer. Collapsed clusters are laid out by the
+// server too, functions are highlighted and searched in the browser.
+(function() {
+"use strict";
+
+var SVGNS = "http://www.w3.org/2000/svg";
+
+var MAX_RESULTS = 100;
+
+var svg = document.getElementById("graph"),
+	viewport = document.getElementById("viewport"),
+	panel = document.getElementById("panel"),
+	search = document.getElementById("search");
+
+var state = {
+	graph: null,
+	nodes: {},      // nodes by ID
+	clusters: {},   // clusters by ID
+	parent: {},     // parent cluster of clusters by ID
+	callers: {},    // edges by callee ID
+	callees: {},    // edges by caller ID
+	collapsed: {},  // IDs of collapsed clusters
+	selected: "",   // ID of selected node
+	query: "",
+	items: {},      // layout items by node ID, collapsed nodes map to their cluster
+	edges: [],      // layout edges with their calls
+	elements: {},   // SVG elements of layout items by item ID
+	view: {x: 0, y: 0, scale: 1},
+	width: 0,
+	height: 0
+};
+
+function setStatus(text) {
+	document.getElementById("status").textContent = text;
+}
+
+function el(name, attrs, parent) {
+	var e = document.createElementNS(SVGNS, name);
+	for (var k in attrs) {
+		e.setAttribute(k, attrs[k]);
+	}
+	if (parent) {
+		parent.appendChild(e);
+	}
+	return e;
+}
+
+function html(name, text, parent) {
+	var e = document.createElement(name);
+	if (text !== undefined) {
+		e.textContent = text;
+	}
+	if (parent) {
+		parent.appendChild(e);
+	}
+	return e;
+}
+
+// ==[ loading ]================================================================
+
+// graphQuery returns server query of the graph, the viewer keeps
+// the same parameters as the image, e.g. focus or grouping.
+function graphQuery(format) {
+	var q = new URLSearchParams(location.search);
+	q.delete("format");
+	if (format) {
+		q.set("format", format);
+	}
+	return q;
+}
+
+// load fetches the graph laid out with collapsed clusters, the view
+// is fitted to the graph unless kept, then is called when shown.
+function load(keepView, then) {
+	setStatus("loading…");
+	document.getElementById("image").href = "/?" + graphQuery("").toString();
+	var q = graphQuery("json");
+	q.set("layout", "true");
+	var collapsed = Object.keys(state.collapsed).sort();
+	if (collapsed.length > 0) {
+		q.set("collapsed", collapsed.join(","));
+	}
+	fetch("/?" + q.toString()).then(function(r) {
+		// analysis is still running
+		if (r.status === 503) {
+			progress();
+			return null;
+		}
+		if (!r.ok) {
+			return r.text().then(function(t) { throw new Error(t); });
+		}
+		return r.json();
+	}).then(function(g) {
+		if (g) {
+			show(g, keepView);
+			if (then) {
+				then();
+			}
+		}
+	}).catch(function(e) {
+		setStatus("loading failed: " + e.message);
+	});
+}
+
+function progress() {
+	fetch("/progress").then(function(r) { return r.json(); }).then(function(p) {
+		setStatus(p.phase + (p.failed ? "" : "…"));
+		if (!p.failed) {
+			setTimeout(load, 1000);
+		}
+	}).catch(function() {
+		setTimeout(load, 1000);
+	});
+}
+
+function show(g, keepView) {
+	state.graph = g;
+	state.nodes = {};
+	state.clusters = {};
+	state.parent = {};
+	state.callers = {};
+	state.callees = {};
+	g.nodes.forEach(function(n) {
+		state.nodes[n.id] = n;
+	});
+	var walk = function(c, parent) {
+		state.clusters[c.id] = c;
+		state.parent[c.id] = parent;
+		(c.clusters || []).forEach(function(sub) { walk(sub, c); });
+	};
+	if (g.cluster) {
+		walk(g.cluster, null);
+	}
+	g.edges.forEach(function(e) {
+		(state.callers[e.callee] = state.callers[e.callee] || []).push(e);
+		(state.callees[e.caller] = state.callees[e.caller] || []).push(e);
+	});
+	if (!state.nodes[state.selected]) {
+		state.selected = "";
+	}
+
+	document.getElementById("title").textContent = g.title;
+	document.title = "go-callvis: " + g.title;
+	setStatus(g.nodes.length + " functions, " + g.edges.length + " calls" +
+		(g.errors ? ", " + g.errors.length + " packages contain errors" : ""));
+
+	render();
+	if (!keepView) {
+		fit();
+	}
+	showPanel();
+}
+
+// collapse collapses or expands the cluster, the graph
+// is laid out again by the server.
+function collapse(id, collapsed) {
+	if (collapsed) {
+		state.collapsed[id] = true;
+	} else {
+		delete state.collapsed[id];
+	}
+	load(true);
+}
+
+// ==[ rendering ]==============================================================
+
+// itemOf returns layout item of the node, which is the outermost
+// collapsed cluster containing the node if any.
+function itemOf(id, byID) {
+	var item = byID[id];
+	for (var c = state.clusters[state.nodes[id].cluster]; c; c = state.parent[c.id]) {
+		item = byID["cluster:" + c.id] || item;
+	}
+	return item;
+}
+
+function pathData(e) {
+	var p = e.points.map(function(p) { return p[0].toFixed(1) + "," + p[1].toFixed(1); });
+	// self call is a loop drawn as a curve
+	if (e.from === e.to && p.length === 4) {
+		return "M" + p[0] + " C" + p.slice(1).join(" ");
+	}
+	return "M" + p.join(" L");
+}
+
+function render() {
+	var l = state.graph.layout, byID = {};
+	state.width = l.width;
+	state.height = l.height;
+	state.items = {};
+	state.elements = {};
+	l.items.forEach(function(item) {
+		byID[item.id] = item;
+	});
+	state.graph.nodes.forEach(function(n) {
+		state.items[n.id] = itemOf(n.id, byID);
+	});
+	state.edges = l.edges.map(function(e) {
+		return {
+			id: e.from + "\n" + e.to,
+			from: byID[e.from],
+			to: byID[e.to],
+			points: e.points,
+			calls: e.calls.map(function(i) { return state.graph.edges[i]; })
+		};
+	});
+	while (viewport.firstChild) {
+		viewport.removeChild(viewport.firstChild);
+	}
+
+	l.clusters.forEach(function(lc) {
+		var c = state.clusters[lc.id];
+		var g = el("g", {"class": "cluster " + (c.kind || "focus")}, viewport);
+		el("rect", {x: lc.x0, y: lc.y0, width: lc.x1 - lc.x0, height: lc.y1 - lc.y0, rx: 4}, g);
+		var label = c.label || c.id;
+		var text = el("text", {x: lc.x0 + 6, y: lc.y0 + 17}, g);
+		if (state.parent[c.id]) {
+			text.textContent = "▾ " + label;
+			el("title", {}, text).textContent = "collapse " + c.id;
+			text.addEventListener("click", function() {
+				collapse(c.id, true);
+			});
+		} else {
+			text.textContent = label;
+		}
+	});
+
+	state.edges.forEach(function(e) {
+		var kinds = {}, external = false;
+		e.calls.forEach(function(call) {
+			kinds[call.kind] = true;
+			var from = state.nodes[call.caller], to = state.nodes[call.callee];
+			external = external || from.package !== to.package;
+		});
+		var cls = "edge " + Object.keys(kinds).join(" ") + (external ? " external" : "");
+		var g = el("g", {"class": cls}, viewport);
+		el("title", {}, g).textContent = e.calls.map(function(call) {
+			return call.caller + " → " + call.callee + " (" + call.kind + ")";
+		}).join("\n");
+		el("path", {d: pathData(e)}, g);
+		state.elements[e.id] = g;
+	});
+
+	l.items.forEach(function(item) {
+		var n = state.nodes[item.id], c = state.clusters[item.collapsed];
+		var cls = "node";
+		if (c) {
+			cls += " collapsed " + (c.kind || "");
+		} else {
+			cls += (n.focused ? " focused" : "") + (n.unreachable ? " unreachable" : "");
+		}
+		var g = el("g", {"class": cls}, viewport);
+		el("title", {}, g).textContent = c ? "expand " + c.id : item.id;
+		var rect = el("rect", {x: item.x - item.w / 2, y: item.y - item.h / 2, width: item.w, height: item.h, rx: 4}, g);
+		if (n && n.color) {
+			rect.style.fill = n.color;
+		}
+		// lines are spaced as estimated by the server layout
+		var size = item.fontSize, line = size * 1.2;
+		var y = item.y - (item.label.length - 1) * line / 2 + size * 0.35;
+		item.label.forEach(function(text, i) {
+			el("text", {x: item.x, y: y + i * line, "font-size": size}, g).textContent = text;
+		});
+		g.addEventListener("click", function(ev) {
+			if (moved) {
+				return;
+			}
+			ev.stopPropagation();
+			if (c) {
+				collapse(c.id, false);
+				return;
+			}
+			select(item.id);
+		});
+		g.addEventListener("dblclick", function(ev) {
+			ev.stopPropagation();
+			if (n) {
+				focus(item.id);
+			}
+		});
+		state.elements[item.id] = g;
+	});
+
+	highlight();
+	applyView();
+}
+
+// highlight marks selected node with its callers and callees
+// and nodes matching the search.
+function highlight() {
+	var related = {};
+	var item = state.items[state.selected];
+	if (item) {
+		related[item.id] = "selected";
+		state.edges.forEach(function(e) {
+			if (e.from === item || e.to === item) {
+				related[e.id] = "related";
+				related[e.from.id] = related[e.from.id] || "related";
+				related[e.to.id] = related[e.to.id] || "related";
+			}
+		});
+	}
+	var query = state.query.toLowerCase();
+	Object.keys(state.elements).forEach(function(id) {
+		var e = state.elements[id];
+		e.classList.remove("selected", "related", "match");
+		if (related[id]) {
+			e.classList.add(related[id]);
+		}
+		if (query && matches(id, query)) {
+			e.classList.add("match");
+		}
+	});
+	svg.classList.toggle("highlight", !!item);
+	svg.classList.toggle("searching", !!query);
+}
+
+function matches(id, query) {
+	var n = state.nodes[id];
+	if (n) {
+		return id.toLowerCase().indexOf(query) >= 0;
+	}
+	var c = state.clusters[id.replace(/^cluster:/, "")];
+	return !!c && collapsedMatches(c, query);
+}
+
+function collapsedMatches(c, query) {
+	return (c.nodes || []).some(function(id) { return id.toLowerCase().indexOf(query) >= 0; }) ||
+		(c.clusters || []).some(function(sub) { return collapsedMatches(sub, query); });
+}
+
+// ==[ interaction ]============================================================
+
+function select(id) {
+	state.selected = id;
+	highlight();
+	showPanel();
+}
+
+// reveal expands clusters containing the node, selects it
+// and moves it to the center of the view.
+function reveal(id) {
+	var n = state.nodes[id];
+	if (!n) {
+		return;
+	}
+	var expanded = false;
+	for (var c = state.clusters[n.cluster]; c; c = state.parent[c.id]) {
+		expanded = expanded || !!state.collapsed[c.id];
+		delete state.collapsed[c.id];
+	}
+	state.selected = id;
+	var center = function() {
+		var item = state.items[id], rect = svg.getBoundingClientRect();
+		state.view.x = rect.width / 2 - item.x * state.view.scale;
+		state.view.y = rect.height / 2 - item.y * state.view.scale;
+		applyView();
+	};
+	if (expanded) {
+		load(true, center);
+		return;
+	}
+	highlight();
+	showPanel();
+	center();
+}
+
+// focus shows graph focused on the function, the query is kept in history.
+function focus(id) {
+	var q = new URLSearchParams(location.search);
+	q.set("f", id);
+	history.pushState(null, "", "?" + q.toString());
+	state.selected = id;
+	load();
+}
+
+function setCollapsed(all) {
+	state.collapsed = {};
+	if (all) {
+		Object.keys(state.clusters).forEach(function(id) {
+			if (state.clusters[id].kind === "package") {
+				state.collapsed[id] = true;
+			}
+		});
+	}
+	load();
+}
+
+function applyView() {
+	var v = state.view;
+	viewport.setAttribute("transform", "translate(" + v.x + "," + v.y + ") scale(" + v.scale + ")");
+}
+
+function fit() {
+	var rect = svg.getBoundingClientRect();
+	if (!state.width || !rect.width) {
+		return;
+	}
+	var scale = Math.min(rect.width / state.width, rect.height / state.height, 1.5);
+	state.view = {
+		x: (rect.width - state.width * scale) / 2,
+		y: (rect.height - state.height * scale) / 2,
+		scale: scale
+	};
+	applyView();
+}
+
+var drag = null, moved = false;
+
+svg.addEventListener("mousedown", function(ev) {
+	drag = {x: ev.clientX, y: ev.clientY, vx: state.view.x, vy: state.view.y};
+	moved = false;
+});
+window.addEventListener("mousemove", function(ev) {
+	if (!drag) {
+		return;
+	}
+	var dx = ev.clientX - drag.x, dy = ev.clientY - drag.y;
+	if (Math.abs(dx) + Math.abs(dy) > 3) {
+		moved = true;
+		svg.classList.add("panning");
+	}
+	state.view.x = drag.vx + dx;
+	state.view.y = drag.vy + dy;
+	applyView();
+});
+window.addEventListener("mouseup", function() {
+	drag = null;
+	svg.classList.remove("panning");
+});
+svg.addEventListener("click", function() {
+	if (!moved && state.selected) {
+		select("");
+	}
+});
+svg.addEventListener("wheel", function(ev) {
+	ev.preventDefault();
+	var rect = svg.getBoundingClientRect(), v = state.view;
+	var scale = Math.min(Math.max(v.scale * Math.exp(-ev.deltaY * 0.002), 0.05), 5);
+	var x = ev.clientX - rect.left, y = ev.clientY - rect.top;
+	v.x = x - (x - v.x) * scale / v.scale;
+	v.y = y - (y - v.y) * scale / v.scale;
+	v.scale = scale;
+	applyView();
+}, {passive: false});
+
+search.addEventListener("input", function() {
+	state.query = search.value.trim();
+	highlight();
+	showPanel();
+});
+search.addEventListener("keydown", function(ev) {
+	if (ev.key === "Enter") {
+		var found = searchResults()[0];
+		if (found) {
+			reveal(found.id);
+		}
+	} else if (ev.key === "Escape") {
+		search.value = "";
+		state.query = "";
+		highlight();
+		showPanel();
+	}
+});
+
+document.getElementById("fit").addEventListener("click", fit);
+document.getElementById("expand").addEventListener("click", function() { setCollapsed(false); });
+document.getElementById("collapse").addEventListener("click", function() { setCollapsed(true); });
+window.addEventListener("popstate", function() { load(); });
+
+// graph is reloaded when sources change, the server
+// marks the page in watch mode
+if (document.body.dataset.watch && window.EventSource) {
+	var events = new EventSource("/events");
+	events.onmessage = function() { load(true); };
+	events.onerror = function() { events.close(); };
+}
+
+// ==[ side panel ]=============================================================
+
+function searchResults() {
+	var query = state.query.toLowerCase();
+	if (!query || !state.graph) {
+		return [];
+	}
+	return state.graph.nodes.filter(function(n) {
+		return n.id.toLowerCase().indexOf(query) >= 0;
+	});
+}
+
+function shortName(n) {
+	return (n.label || n.name || n.id).replace(/\n/g, ".");
+}
+
+function position(p) {
+	if (!p || !p.file) {
+		return "";
+	}
+	return p.file.split(/[\\/]/).pop() + ":" + p.line;
+}
+
+function nodeList(title, edges, other, parent) {
+	html("h3", title + " (" + edges.length + ")", parent);
+	var ul = html("ul", undefined, parent);
+	edges.forEach(function(e) {
+		var n = state.nodes[e[other]];
+		var li = html("li", shortName(n), ul);
+		li.title = n.id;
+		var sites = (e.sites || []).map(position).join(", ");
+		html("div", e.kind + (sites ? " at " + sites : ""), li).className = "sites";
+		li.addEventListener("click", function() { reveal(n.id); });
+	});
+}
+
+function showPanel() {
+	panel.textContent = "";
+	if (!state.graph) {
+		return;
+	}
+
+	if (state.query) {
+		var results = searchResults();
+		html("h3", "Search results (" + results.length + ")", panel);
+		var ul = html("ul", undefined, panel);
+		results.slice(0, MAX_RESULTS).forEach(function(n) {
+			var li = html("li", shortName(n), ul);
+			li.title = n.id;
+			li.addEventListener("click", function() { reveal(n.id); });
+		});
+	}
+
+	var n = state.nodes[state.selected];
+	if (!n) {
+		if (!state.query) {
+			html("p", "Click a function to show its details, callers and callees. " +
+				"Double click focuses the graph on the function. " +
+				"Click labels of clusters to collapse them.", panel).className = "hint";
+			(state.graph.errors || []).forEach(function(e) {
+				html("pre", e, panel);
+			});
+		}
+		return;
+	}
+
+	html("h2", n.name || n.id, panel);
+	var table = html("table", undefined, panel);
+	var row = function(name, value) {
+		if (!value) {
+			return;
+		}
+		var tr = html("tr", undefined, table);
+		html("td", name, tr);
+		html("td", value, tr);
+	};
+	row("id", n.id);
+	row("package", n.package);
+	row("receiver", n.receiver);
+	row("position", n.position && n.position.file ? n.position.file + ":" + n.position.line : "");
+	row("exported", n.exported ? "yes" : "no");
+	row("unreachable", n.unreachable ? "yes" : "");
+
+	var focusLink = html("a", "focus on this function", panel);
+	focusLink.href = "#";
+	focusLink.addEventListener("click", function(ev) {
+		ev.preventDefault();
+		focus(n.id);
+	});
+
+	nodeList("Callers", state.callers[n.id] || [], "caller", panel);
+	nodeList("Callees", state.callees[n.id] || [], "callee", panel);
+}
+
+load();
+})();
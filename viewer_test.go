@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestViewerPage(t *testing.T) {
+	page := []byte("<html>\n<body>\n</body>\n</html>\n")
+	if got := string(viewerPage(page)); got != string(page) {
+		t.Errorf("page without watch mode changed:\n%s", got)
+	}
+
+	defer func(watch bool) { *watchFlag = watch }(*watchFlag)
+	*watchFlag = true
+	if got := string(viewerPage(page)); !strings.Contains(got, `<body data-watch="true">`) {
+		t.Errorf("page in watch mode is not marked:\n%s", got)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	watchInterval = time.Second
+	watchDebounce = 500 * time.Millisecond
+)
+
+// Sources returns Go files of analyzed packages and packages of the main
+// module they import, together with their directories to notice new files.
+func (a *analysis) Sources() ([]string, error) {
+	cfg := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedModule,
+		Tests:      a.cfg.Tests,
+		Dir:        a.cfg.Dir,
+		BuildFlags: a.cfg.BuildFlags,
+	}
+	initial, err := packages.Load(cfg, a.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	isInitial := make(map[*packages.Package]bool)
+	for _, p := range initial {
+		isInitial[p] = true
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if !isInitial[p] && (p.Module == nil || !p.Module.Main) {
+			return
+		}
+		for _, f := range p.GoFiles {
+			add(f)
+			add(filepath.Dir(f))
+		}
+	})
+
+	return files, nil
+}
+
+// Reload runs analysis again with the same options.
+func (a *analysis) Reload() (*analysis, error) {
+	b := new(analysis)
+	err := b.DoAnalysis(a.algo, a.cfg.Dir, a.cfg.Tests, a.lib, a.allowErrors, a.cacheDir, a.args)
+	if err != nil {
+		return nil, err
+	}
+	b.generation = a.generation + 1
+	return b, nil
+}
+
+// modTimes returns modification times of files, missing files are omitted.
+func modTimes(files []string) map[string]time.Time {
+	times := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			times[f] = fi.ModTime()
+		}
+	}
+	return times
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for f, t := range a {
+		if !b[f].Equal(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// watchSources polls sources of the current analysis and when they change,
+// the analysis is done again in background and swapped once finished.
+func watchSources(reloads *reloadNotifier) {
+	for {
+		// listing fails e.g. while go.mod is broken, so it is retried
+		files, err := Analysis.Load().Sources()
+		if err != nil {
+			log.Printf("watch: listing sources failed, retrying: %v", err)
+		}
+		for err != nil {
+			time.Sleep(watchInterval)
+			files, err = Analysis.Load().Sources()
+		}
+		logf("watching %d files", len(files))
+
+		times := modTimes(files)
+		for {
+			time.Sleep(watchInterval)
+			if !sameModTimes(times, modTimes(files)) {
+				break
+			}
+		}
+
+		// wait until files stop changing
+		for {
+			times = modTimes(files)
+			time.Sleep(watchDebounce)
+			if sameModTimes(times, modTimes(files)) {
+				break
+			}
+		}
+
+		log.Println("sources changed, re-analyzing")
+
+		a, err := Analysis.Load().Reload()
+		if err != nil {
+			log.Printf("re-analysis failed: %v", err)
+			continue
+		}
+		Analysis.Store(a)
+		renderCache.Reset()
+
+		log.Println("analysis updated, reloading browsers")
+		reloads.Notify()
+	}
+}
+
+// ==[ type def/func: reloadNotifier   ]==========================================
+
+// reloadNotifier tells open browser tabs to reload using server-sent events.
+type reloadNotifier struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func newReloadNotifier() *reloadNotifier {
+	return &reloadNotifier{
+		clients: make(map[chan struct{}]bool),
+	}
+}
+
+func (n *reloadNotifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for c := range n.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *reloadNotifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan struct{}, 1)
+	n.mu.Lock()
+	n.clients[c] = true
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.clients, c)
+		n.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-c:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// reloadScript reloads the SVG document when analysis is updated.
+const reloadScript = `<script type="text/javascript"><![CDATA[
+new EventSource("/events").onmessage = function() { location.reload(); };
+]]></script>
+`
+
+// serveImage serves image file, SVG images get reload script in watch mode.
+func serveImage(w http.ResponseWriter, r *http.Request, img string, format string) {
+	if !*watchFlag || format != "svg" {
+		http.ServeFile(w, r, img)
+		return
+	}
+
+	svg, err := os.ReadFile(img)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serveSVG(w, svg)
+}
+
+// serveSVG serves SVG document, it gets reload script in watch mode.
+func serveSVG(w http.ResponseWriter, svg []byte) {
+	if i := bytes.LastIndex(svg, []byte("</svg>")); *watchFlag && i >= 0 {
+		svg = append(svg[:i:i], append([]byte(reloadScript), svg[i:]...)...)
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(svg)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatchModTimes(t *testing.T) {
+	dir := testModule(t, map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"pkg/pkg.go": "package pkg\n",
+	})
+	a := new(analysis)
+	if err := a.DoAnalysis(CallGraphTypeStatic, dir, false, false, false, "", []string{"."}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := a.Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.go")
+	for _, f := range []string{main, dir} {
+		if !slices.Contains(files, f) {
+			t.Errorf("%s is not watched, sources: %q", f, files)
+		}
+	}
+	// packages not imported by the analyzed ones are not watched
+	if slices.Contains(files, filepath.Join(dir, "pkg", "pkg.go")) {
+		t.Error("package not imported is watched")
+	}
+
+	times := modTimes(files)
+	if !sameModTimes(times, modTimes(files)) {
+		t.Fatal("unchanged sources differ")
+	}
+
+	// file systems may have coarse times, so they are set explicitly
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(main, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if sameModTimes(times, modTimes(files)) {
+		t.Error("modified file was not detected")
+	}
+
+	times = modTimes(files)
+	if err := os.Remove(main); err != nil {
+		t.Fatal(err)
+	}
+	if sameModTimes(times, modTimes(files)) {
+		t.Error("removed file was not detected")
+	}
+}